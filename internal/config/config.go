@@ -0,0 +1,221 @@
+// Package config holds Config, the per-guild GuildConfig/Store it's layered with, and the shared
+// state built from them (the live config pointer, the guild store) that every subsystem reads.
+// Keeping these here rather than in main lets each subsystem import just this package instead of
+// depending on one another.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+type Config struct {
+	Google struct {
+		// CredentialsMode selects how Rolly authenticates with Google: "oauth2" (default) for
+		// the interactive 3-legged flow, "service_account" to use a service account key file at
+		// CredentialsPath, or "adc" for Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS
+		// or GCE/GKE metadata).
+		CredentialsMode string
+		CredentialsPath string
+		TokenPath       string
+		RedirectURL     string
+
+		// CallbackListenAddr is the address the OAuth2 callback server binds to, for handling
+		// Google's redirect during /reauth. Defaults to the host:port parsed out of RedirectURL.
+		CallbackListenAddr string
+	}
+
+	Discord struct {
+		ApplicationID string
+		BotToken      string
+		BotOwners     []string
+		BotServer     string
+
+		// StoreBackend selects the Store used for per-guild config: "toml" (default) for one
+		// file per guild under StorePath, or "bolt" for a single BoltDB file at StorePath.
+		StoreBackend string
+		StorePath    string
+
+		// Templates holds the msgtemplate strings behind the reaction handlers' result messages,
+		// letting operators customise/localise them without recompiling.
+		Templates Templates
+	}
+
+	// DryRun, when true, makes the sheet update loop log the batch requests it would submit
+	// instead of actually calling BatchUpdate, so admins can validate range configs against a
+	// live sheet without risking a write.
+	DryRun bool
+
+	Database struct {
+		// Path is the SQLite database file used for reaction state and the pending-update
+		// outbox. Defaults to "rolly.db".
+		Path string
+	}
+}
+
+type ColourPriority struct {
+	Colour   string
+	Priority int
+}
+
+// Templates holds the msgtemplate strings behind the reaction handlers' result messages. Each is a
+// fasttemplate-style string with {name} placeholders (e.g. "nick", "emoji", "colour") and
+// "{plural:<plural>:<singular>}" placeholders resolved against a count - see internal/msgtemplate
+// for how they're resolved. The values each one receives are set where it's rendered (the
+// reactions subsystem's handlers, and the sheets subsystem's poll tally writer for OnRangeUpdated).
+type Templates struct {
+	OnReactAdd        string
+	OnReactRemove     string
+	OnReactRemoveKeep string
+	OnReactRemoveAll  string
+	OnRangeUpdated    string
+}
+
+// NameColourUpdate is a single pending cell colour change. Priority mirrors ColourPriority.Priority
+// so that when several updates for the same name are coalesced within a tick, the one from the
+// highest-priority reaction (lowest Priority value) wins.
+type NameColourUpdate struct {
+	GuildID  string
+	Name     string
+	Colour   string
+	Priority int
+}
+
+// ResetPriority is the Priority given to updates that clear a user's colour back to the default
+// (e.g. all reactions removed). It's lower than any real ColourPriority so it always wins a
+// same-tick coalesce against a leftover reaction update.
+const ResetPriority = -1
+
+// PollTallyUpdate is a poll's current vote tally, destined for the sheet range its owner targeted
+// with /poll create. Counts is in the same order as the Poll's OptionEmojis/OptionText, so it's
+// written as a single row starting at RangeA1's top-left cell.
+type PollTallyUpdate struct {
+	GuildID string
+	RangeA1 string
+	Counts  []int
+}
+
+// Current is the live, possibly-hot-reloaded Config every subsystem reads from. It's set once in
+// main before any subsystem is initialised, and replaced wholesale (under Mutex) on a SIGHUP
+// reload.
+var Current *Config
+
+// Mutex guards all reads and writes of Current, both in-place mutation by slash-command handlers
+// (e.g. /dryrun) and wholesale replacement of the pointer on a SIGHUP reload.
+var Mutex sync.RWMutex
+
+// IsBotOwner returns whether userID is listed in Current.Discord.BotOwners.
+func IsBotOwner(userID string) bool {
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+
+	for _, owner := range Current.Discord.BotOwners {
+		if owner == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDryRun returns whether Current.DryRun is currently enabled.
+func IsDryRun() bool {
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return Current.DryRun
+}
+
+// CurrentTemplates returns a copy of Current.Discord.Templates, safe to call concurrently with a
+// SIGHUP reload.
+func CurrentTemplates() Templates {
+	Mutex.RLock()
+	defer Mutex.RUnlock()
+	return Current.Discord.Templates
+}
+
+// LoadConfig loads config in from the given path.
+func LoadConfig(path string) (*Config, error) {
+	var cfg Config
+
+	// Set defaults
+	if cfg.Google.CredentialsMode == "" {
+		cfg.Google.CredentialsMode = "oauth2"
+	}
+	if cfg.Google.CredentialsPath == "" {
+		cfg.Google.CredentialsPath = "credentials.json"
+	}
+	if cfg.Google.TokenPath == "" {
+		cfg.Google.TokenPath = "token.json"
+	}
+	if cfg.Database.Path == "" {
+		cfg.Database.Path = "rolly.db"
+	}
+	if cfg.Discord.Templates.OnReactAdd == "" {
+		cfg.Discord.Templates.OnReactAdd = "{nick} reacted with '{emoji}', changing their cell to {colour}"
+	}
+	if cfg.Discord.Templates.OnReactRemove == "" {
+		cfg.Discord.Templates.OnReactRemove = "{nick} removed their '{emoji}' react, changing their cell to {colour}"
+	}
+	if cfg.Discord.Templates.OnReactRemoveKeep == "" {
+		cfg.Discord.Templates.OnReactRemoveKeep = "{nick} removed their '{emoji}' react, but they still have a '{next_emoji}' react. Changing their cell to {colour}"
+	}
+	if cfg.Discord.Templates.OnReactRemoveAll == "" {
+		cfg.Discord.Templates.OnReactRemoveAll = "{nick} removed all their reacts"
+	}
+	if cfg.Discord.Templates.OnRangeUpdated == "" {
+		cfg.Discord.Templates.OnRangeUpdated = "Updated {range} with {count} {plural:votes:vote}"
+	}
+
+	// Try read in from the given path
+	_, err := toml.DecodeFile(path, &cfg)
+	if err != nil {
+		return &cfg, err
+	}
+
+	// Check for required values
+	if cfg.Google.RedirectURL == "" {
+		return &cfg, fmt.Errorf("missing Google redirect URL")
+	}
+	if cfg.Discord.BotToken == "" {
+		return &cfg, fmt.Errorf("missing Discord bot token")
+	}
+	if cfg.Discord.BotServer == "" {
+		return &cfg, fmt.Errorf("missing Discord server ID")
+	}
+
+	return &cfg, nil
+}
+
+// SaveConfig writes cfg out as TOML, via a temp file in the same directory plus a rename, so a
+// crash or a concurrent manual edit mid-write can't leave config.toml truncated or corrupt.
+func SaveConfig(cfg *Config, path string) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for writing: %v", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once the rename below succeeds
+
+	e := toml.NewEncoder(tempFile)
+	if err := e.Encode(cfg); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed writing config to \"%s\": %v", tempPath, err)
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed syncing file \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed closing file \"%s\": %v", tempPath, err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed replacing \"%s\": %v", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,633 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	set "github.com/deckarep/golang-set/v2"
+	"go.etcd.io/bbolt"
+)
+
+// GuildConfig holds the settings that used to live directly on Config, now scoped per Discord
+// guild so one Rolly instance can serve more than one server. Everything else (bot token,
+// application ID, Google credentials paths) stays global on Config, since a single process shares
+// one Discord bot and one set of Google credentials across every guild it serves.
+type GuildConfig struct {
+	SheetID           string
+	SheetRangesSlice  []string `toml:"SheetRanges" json:"SheetRanges"`
+	sheetRanges       set.Set[string]
+	RollCallChannelID string
+	ReactionColours   map[string]ColourPriority
+	ReactionRules     []ReactionRule
+}
+
+// SheetRanges returns the guild's configured sheet ranges.
+func (g *GuildConfig) SheetRanges() []string {
+	return g.sheetRanges.ToSlice()
+}
+
+// SetSheetRanges replaces the guild's configured sheet ranges.
+func (g *GuildConfig) SetSheetRanges(ranges []string) {
+	g.sheetRanges.Clear()
+	g.sheetRanges.Append(ranges...)
+}
+
+// AddSheetRange adds a single range to the guild's configured sheet ranges.
+func (g *GuildConfig) AddSheetRange(r string) {
+	g.sheetRanges.Add(r)
+}
+
+// MatchType selects how a ReactionRule's Match field is interpreted against an emoji's name/ID.
+type MatchType string
+
+const (
+	MatchLiteral MatchType = "literal"
+	MatchGlob    MatchType = "glob"
+	MatchRegex   MatchType = "regex"
+)
+
+// ReactionRule is a single reaction-triggered colour change, matched against an emoji's name or ID
+// by MatchType, that wins over ReactionColours entries with a higher Priority value (lower number =
+// more important, same convention as ColourPriority.Priority) unless the reacting message's channel or
+// the reactor's roles fall in the rule's exclusion lists.
+type ReactionRule struct {
+	MatchType        MatchType
+	Match            string
+	Colour           string
+	Priority         int
+	ExcludedChannels []string
+	ExcludedRoles    []string
+}
+
+// DefaultGuildConfig returns a GuildConfig with the same defaults LoadConfig used to apply to the
+// global config, so a guild Rolly hasn't seen before still gets sensible reaction colours.
+func DefaultGuildConfig() *GuildConfig {
+	return &GuildConfig{
+		sheetRanges: set.NewSet[string](),
+		ReactionColours: map[string]ColourPriority{
+			"✅": {Colour: "00ff00", Priority: 1},
+			"❔": {Colour: "ffff00", Priority: 2},
+			"❌": {Colour: "ff0000", Priority: 3},
+		},
+	}
+}
+
+// RollCall tracks a single roll call message: where it was sent, who created it, when it (and
+// optionally expires), and the colour each user who's reacted currently holds. Persisting this
+// lets several roll calls run at once without colliding and lets the bot resume tracking them
+// (including their expiry) after a restart.
+type RollCall struct {
+	MessageID   string
+	ChannelID   string
+	GuildID     string
+	CreatorID   string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	Closed      bool
+	UserColours map[string]string
+}
+
+// Poll tracks a single /poll message: its options, who's currently voted for which (so tallies can
+// be recomputed live off the same reaction events that drive RollCall colours), and the sheet
+// range its tallies are mirrored into, if any.
+type Poll struct {
+	MsgID        string
+	ChannelID    string
+	GuildID      string
+	OwnerID      string
+	Title        string
+	OptionEmojis []string
+	OptionText   []string
+	UserVotes    map[string]string // userID -> emoji
+	Finished     bool
+	RangeA1      string
+}
+
+// Store persists GuildConfig values keyed by Discord guild ID, RollCall values keyed by message ID,
+// and Poll values keyed by message ID.
+type Store interface {
+	// Get returns the GuildConfig for the given guild, or a freshly defaulted one if the guild
+	// hasn't been seen before.
+	Get(guildID string) (*GuildConfig, error)
+	// Put persists the given GuildConfig for the given guild.
+	Put(guildID string, config *GuildConfig) error
+	// List returns the IDs of every guild with a persisted config.
+	List() ([]string, error)
+
+	// GetRollCall returns the persisted RollCall for the given message ID, or nil if there isn't
+	// one.
+	GetRollCall(messageID string) (*RollCall, error)
+	// PutRollCall persists the given RollCall.
+	PutRollCall(rollCall *RollCall) error
+	// ListRollCalls returns every persisted RollCall, across every guild.
+	ListRollCalls() ([]*RollCall, error)
+
+	// GetPoll returns the persisted Poll for the given message ID, or nil if there isn't one.
+	GetPoll(msgID string) (*Poll, error)
+	// PutPoll persists the given Poll.
+	PutPoll(poll *Poll) error
+	// ListPolls returns every persisted Poll, across every guild.
+	ListPolls() ([]*Poll, error)
+}
+
+// GuildStore is the Store used for per-guild config and roll calls. It's set once in main, before
+// any subsystem is initialised, and never reassigned, so it needs no mutex of its own; each Store
+// implementation guards its own reads and writes.
+var GuildStore Store
+
+// GuildConfigOrDefault fetches the GuildConfig for the given guild from GuildStore, falling back
+// to a fresh default (and logging the failure) if the store couldn't be read.
+func GuildConfigOrDefault(guildID string) *GuildConfig {
+	guildConfig, err := GuildStore.Get(guildID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed getting guild config for \"%s\": %v\n", guildID, err)
+		return DefaultGuildConfig()
+	}
+	return guildConfig
+}
+
+// NewStore constructs the Store selected by cfg.Discord.StoreBackend ("toml", the default, or
+// "bolt"), rooted at cfg.Discord.StorePath.
+func NewStore(cfg *Config) (Store, error) {
+	path := cfg.Discord.StorePath
+	switch cfg.Discord.StoreBackend {
+	case "", "toml":
+		if path == "" {
+			path = "guilds"
+		}
+		return newTomlDirStore(path)
+	case "bolt":
+		if path == "" {
+			path = "guilds.db"
+		}
+		return newBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.Discord.StoreBackend)
+	}
+}
+
+// tomlDirStore is a Store backed by one TOML file per guild (and one per roll call or poll, in
+// "rollcalls"/"polls" subdirectories) in a directory, written via a temp file plus rename so a
+// crash or concurrent read can't observe a truncated file.
+type tomlDirStore struct {
+	dir          string
+	rollCallsDir string
+	pollsDir     string
+	mutex        sync.Mutex
+}
+
+func newTomlDirStore(dir string) (*tomlDirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create guild config directory \"%s\": %v", dir, err)
+	}
+
+	rollCallsDir := filepath.Join(dir, "rollcalls")
+	if err := os.MkdirAll(rollCallsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create roll call directory \"%s\": %v", rollCallsDir, err)
+	}
+
+	pollsDir := filepath.Join(dir, "polls")
+	if err := os.MkdirAll(pollsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create poll directory \"%s\": %v", pollsDir, err)
+	}
+
+	return &tomlDirStore{dir: dir, rollCallsDir: rollCallsDir, pollsDir: pollsDir}, nil
+}
+
+func (s *tomlDirStore) path(guildID string) string {
+	return filepath.Join(s.dir, guildID+".toml")
+}
+
+func (s *tomlDirStore) Get(guildID string) (*GuildConfig, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guildConfig := DefaultGuildConfig()
+	_, err := toml.DecodeFile(s.path(guildID), guildConfig)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return guildConfig, nil
+		}
+		return nil, fmt.Errorf("failed to decode guild config for \"%s\": %v", guildID, err)
+	}
+
+	guildConfig.sheetRanges = set.NewSet[string]()
+	for _, sheetRange := range guildConfig.SheetRangesSlice {
+		guildConfig.sheetRanges.Add(sheetRange)
+	}
+
+	return guildConfig, nil
+}
+
+func (s *tomlDirStore) Put(guildID string, guildConfig *GuildConfig) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guildConfig.SheetRangesSlice = guildConfig.sheetRanges.ToSlice()
+
+	tempFile, err := os.CreateTemp(s.dir, guildID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for writing: %v", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once the rename below succeeds
+
+	e := toml.NewEncoder(tempFile)
+	if err := e.Encode(guildConfig); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed writing guild config to \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed syncing file \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed closing file \"%s\": %v", tempPath, err)
+	}
+
+	if err := os.Rename(tempPath, s.path(guildID)); err != nil {
+		return fmt.Errorf("failed replacing \"%s\": %v", s.path(guildID), err)
+	}
+
+	return nil
+}
+
+func (s *tomlDirStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guild config directory \"%s\": %v", s.dir, err)
+	}
+
+	guildIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+		guildIDs = append(guildIDs, strings.TrimSuffix(entry.Name(), ".toml"))
+	}
+
+	return guildIDs, nil
+}
+
+func (s *tomlDirStore) rollCallPath(messageID string) string {
+	return filepath.Join(s.rollCallsDir, messageID+".toml")
+}
+
+func (s *tomlDirStore) GetRollCall(messageID string) (*RollCall, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.getRollCallLocked(messageID)
+}
+
+// getRollCallLocked is GetRollCall without acquiring s.mutex, for callers that already hold it.
+func (s *tomlDirStore) getRollCallLocked(messageID string) (*RollCall, error) {
+	var rollCall RollCall
+	_, err := toml.DecodeFile(s.rollCallPath(messageID), &rollCall)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to decode roll call \"%s\": %v", messageID, err)
+	}
+
+	return &rollCall, nil
+}
+
+func (s *tomlDirStore) PutRollCall(rollCall *RollCall) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tempFile, err := os.CreateTemp(s.rollCallsDir, rollCall.MessageID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for writing: %v", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once the rename below succeeds
+
+	e := toml.NewEncoder(tempFile)
+	if err := e.Encode(rollCall); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed writing roll call to \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed syncing file \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed closing file \"%s\": %v", tempPath, err)
+	}
+
+	if err := os.Rename(tempPath, s.rollCallPath(rollCall.MessageID)); err != nil {
+		return fmt.Errorf("failed replacing \"%s\": %v", s.rollCallPath(rollCall.MessageID), err)
+	}
+
+	return nil
+}
+
+func (s *tomlDirStore) ListRollCalls() ([]*RollCall, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := os.ReadDir(s.rollCallsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roll call directory \"%s\": %v", s.rollCallsDir, err)
+	}
+
+	rollCalls := make([]*RollCall, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+
+		rollCall, err := s.getRollCallLocked(strings.TrimSuffix(entry.Name(), ".toml"))
+		if err != nil {
+			return nil, err
+		}
+		if rollCall != nil {
+			rollCalls = append(rollCalls, rollCall)
+		}
+	}
+
+	return rollCalls, nil
+}
+
+func (s *tomlDirStore) pollPath(msgID string) string {
+	return filepath.Join(s.pollsDir, msgID+".toml")
+}
+
+func (s *tomlDirStore) GetPoll(msgID string) (*Poll, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.getPollLocked(msgID)
+}
+
+// getPollLocked is GetPoll without acquiring s.mutex, for callers that already hold it.
+func (s *tomlDirStore) getPollLocked(msgID string) (*Poll, error) {
+	var poll Poll
+	_, err := toml.DecodeFile(s.pollPath(msgID), &poll)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to decode poll \"%s\": %v", msgID, err)
+	}
+
+	return &poll, nil
+}
+
+func (s *tomlDirStore) PutPoll(poll *Poll) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tempFile, err := os.CreateTemp(s.pollsDir, poll.MsgID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for writing: %v", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once the rename below succeeds
+
+	e := toml.NewEncoder(tempFile)
+	if err := e.Encode(poll); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed writing poll to \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed syncing file \"%s\": %v", tempPath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed closing file \"%s\": %v", tempPath, err)
+	}
+
+	if err := os.Rename(tempPath, s.pollPath(poll.MsgID)); err != nil {
+		return fmt.Errorf("failed replacing \"%s\": %v", s.pollPath(poll.MsgID), err)
+	}
+
+	return nil
+}
+
+func (s *tomlDirStore) ListPolls() ([]*Poll, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := os.ReadDir(s.pollsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list poll directory \"%s\": %v", s.pollsDir, err)
+	}
+
+	polls := make([]*Poll, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+
+		poll, err := s.getPollLocked(strings.TrimSuffix(entry.Name(), ".toml"))
+		if err != nil {
+			return nil, err
+		}
+		if poll != nil {
+			polls = append(polls, poll)
+		}
+	}
+
+	return polls, nil
+}
+
+// guildConfigBucket is the single bbolt bucket guild configs are stored under, keyed by guild ID
+// with a JSON-encoded GuildConfig as the value.
+var guildConfigBucket = []byte("guild_configs")
+
+// rollCallBucket is the single bbolt bucket roll calls are stored under, keyed by message ID with
+// a JSON-encoded RollCall as the value.
+var rollCallBucket = []byte("roll_calls")
+
+// pollBucket is the single bbolt bucket polls are stored under, keyed by message ID with a
+// JSON-encoded Poll as the value.
+var pollBucket = []byte("polls")
+
+// boltStore is a Store backed by a single BoltDB file. Preferred over the TOML directory store
+// when a deployment wants every guild's config in one file with atomic transactional writes,
+// rather than relying on per-file rename semantics.
+type boltStore struct {
+	db *bbolt.DB
+}
+
+func newBoltStore(path string) (*boltStore, error) {
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database \"%s\": %v", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(guildConfigBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(rollCallBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(pollBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guild config bucket: %v", err)
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Get(guildID string) (*GuildConfig, error) {
+	guildConfig := DefaultGuildConfig()
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(guildConfigBucket).Get([]byte(guildID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, guildConfig)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild config for \"%s\": %v", guildID, err)
+	}
+
+	guildConfig.sheetRanges = set.NewSet[string]()
+	for _, sheetRange := range guildConfig.SheetRangesSlice {
+		guildConfig.sheetRanges.Add(sheetRange)
+	}
+
+	return guildConfig, nil
+}
+
+func (s *boltStore) Put(guildID string, guildConfig *GuildConfig) error {
+	guildConfig.SheetRangesSlice = guildConfig.sheetRanges.ToSlice()
+
+	data, err := json.Marshal(guildConfig)
+	if err != nil {
+		return fmt.Errorf("failed to encode guild config for \"%s\": %v", guildID, err)
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(guildConfigBucket).Put([]byte(guildID), data)
+	})
+}
+
+func (s *boltStore) List() ([]string, error) {
+	var guildIDs []string
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(guildConfigBucket).ForEach(func(k, _ []byte) error {
+			guildIDs = append(guildIDs, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guild configs: %v", err)
+	}
+
+	return guildIDs, nil
+}
+
+func (s *boltStore) GetRollCall(messageID string) (*RollCall, error) {
+	var rollCall *RollCall
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(rollCallBucket).Get([]byte(messageID))
+		if data == nil {
+			return nil
+		}
+		rollCall = &RollCall{}
+		return json.Unmarshal(data, rollCall)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roll call \"%s\": %v", messageID, err)
+	}
+
+	return rollCall, nil
+}
+
+func (s *boltStore) PutRollCall(rollCall *RollCall) error {
+	data, err := json.Marshal(rollCall)
+	if err != nil {
+		return fmt.Errorf("failed to encode roll call \"%s\": %v", rollCall.MessageID, err)
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(rollCallBucket).Put([]byte(rollCall.MessageID), data)
+	})
+}
+
+func (s *boltStore) ListRollCalls() ([]*RollCall, error) {
+	var rollCalls []*RollCall
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(rollCallBucket).ForEach(func(_, data []byte) error {
+			var rollCall RollCall
+			if err := json.Unmarshal(data, &rollCall); err != nil {
+				return err
+			}
+			rollCalls = append(rollCalls, &rollCall)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roll calls: %v", err)
+	}
+
+	return rollCalls, nil
+}
+
+func (s *boltStore) GetPoll(msgID string) (*Poll, error) {
+	var poll *Poll
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(pollBucket).Get([]byte(msgID))
+		if data == nil {
+			return nil
+		}
+		poll = &Poll{}
+		return json.Unmarshal(data, poll)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read poll \"%s\": %v", msgID, err)
+	}
+
+	return poll, nil
+}
+
+func (s *boltStore) PutPoll(poll *Poll) error {
+	data, err := json.Marshal(poll)
+	if err != nil {
+		return fmt.Errorf("failed to encode poll \"%s\": %v", poll.MsgID, err)
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pollBucket).Put([]byte(poll.MsgID), data)
+	})
+}
+
+func (s *boltStore) ListPolls() ([]*Poll, error) {
+	var polls []*Poll
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pollBucket).ForEach(func(_, data []byte) error {
+			var poll Poll
+			if err := json.Unmarshal(data, &poll); err != nil {
+				return err
+			}
+			polls = append(polls, &poll)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %v", err)
+	}
+
+	return polls, nil
+}
@@ -0,0 +1,142 @@
+// Package db provides a SQLite-backed store for reaction state (for the "next best colour"
+// lookup when a reaction is removed) and an outbox of pending name-colour updates that survives
+// a restart. Schema changes live as numbered .sql files under migrations/, embedded into the
+// binary and applied in order, tracked in a schema_migrations table.
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// DB wraps a SQLite connection holding reaction state and the pending-update outbox.
+type DB struct {
+	conn *sqlx.DB
+}
+
+// Open opens (creating if necessary) the SQLite database at path and applies any migrations that
+// haven't already been run against it.
+func Open(path string) (*DB, error) {
+	conn, err := sqlx.Connect("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database \"%s\": %v", path, err)
+	}
+
+	d := &DB{conn: conn}
+	if err := d.migrate(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Close closes the underlying database connection.
+func (d *DB) Close() error {
+	return d.conn.Close()
+}
+
+// migration is a single numbered schema change loaded from migrations/.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrate creates the schema_migrations table if it doesn't exist, then applies every embedded
+// migration whose version is higher than the highest one already recorded there, in ascending
+// order, each in its own transaction.
+func (d *DB) migrate() error {
+	if _, err := d.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("failed creating schema_migrations table: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	if err := d.conn.Get(&applied, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed checking applied migrations: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= applied {
+			continue
+		}
+
+		tx, err := d.conn.Beginx()
+		if err != nil {
+			return fmt.Errorf("failed starting transaction for migration %d: %v", m.version, err)
+		}
+
+		if _, err := tx.Exec(m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed applying migration %d (%s): %v", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed recording migration %d (%s): %v", m.version, m.name, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed committing migration %d (%s): %v", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadMigrations reads every embedded migrations/*.sql file and returns them sorted by their
+// leading numeric version (e.g. "0001_init.sql" -> version 1).
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed reading embedded migrations: %v", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			return nil, fmt.Errorf("migration file \"%s\" isn't named \"<version>_<name>.sql\"", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration file \"%s\" doesn't start with a numeric version: %v", entry.Name(), err)
+		}
+
+		contents, err := migrationsFS.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed reading migration \"%s\": %v", entry.Name(), err)
+		}
+
+		migrations = append(migrations, migration{version: version, name: entry.Name(), sql: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import "fmt"
+
+// OutboxEntry is a pending name-colour update that hasn't yet been handed off to the in-memory
+// update queue, kept so it survives a restart between being computed and being sent.
+type OutboxEntry struct {
+	ID       int64
+	GuildID  string `db:"guild_id"`
+	Name     string
+	Colour   string
+	Priority int
+}
+
+// EnqueueOutbox durably records a pending update, returning its ID for a later DeleteOutbox once
+// it's been handed off successfully.
+func (d *DB) EnqueueOutbox(guildID, name, colour string, priority int) (int64, error) {
+	result, err := d.conn.Exec(`INSERT INTO outbox (guild_id, name, colour, priority) VALUES (?, ?, ?, ?)`,
+		guildID, name, colour, priority)
+	if err != nil {
+		return 0, fmt.Errorf("failed enqueuing outbox entry: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed reading outbox entry id: %v", err)
+	}
+
+	return id, nil
+}
+
+// DeleteOutbox removes a pending update once it's been handed off to the update queue.
+func (d *DB) DeleteOutbox(id int64) error {
+	_, err := d.conn.Exec(`DELETE FROM outbox WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed deleting outbox entry: %v", err)
+	}
+	return nil
+}
+
+// ListOutbox returns every pending update left over from a previous run, oldest first, so they
+// can be replayed into the update queue on startup.
+func (d *DB) ListOutbox() ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := d.conn.Select(&entries, `SELECT id, guild_id, name, colour, priority FROM outbox ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing outbox entries: %v", err)
+	}
+	return entries, nil
+}
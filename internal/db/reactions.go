@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ReactionState is the colour a single emoji reaction from a user on a message currently resolves
+// to, as recorded when the reaction was added.
+type ReactionState struct {
+	GuildID   string `db:"guild_id"`
+	UserID    string `db:"user_id"`
+	MessageID string `db:"message_id"`
+	Emoji     string
+	Priority  int
+	Colour    string
+}
+
+// UpsertReaction records (or updates) the colour a user's reaction on a message resolves to.
+func (d *DB) UpsertReaction(state ReactionState) error {
+	_, err := d.conn.Exec(`INSERT INTO reactions (guild_id, user_id, message_id, emoji, priority, colour)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (guild_id, user_id, message_id, emoji) DO UPDATE SET priority = excluded.priority, colour = excluded.colour`,
+		state.GuildID, state.UserID, state.MessageID, state.Emoji, state.Priority, state.Colour)
+	if err != nil {
+		return fmt.Errorf("failed upserting reaction state: %v", err)
+	}
+	return nil
+}
+
+// DeleteReaction removes a single recorded reaction, e.g. when a user removes it.
+func (d *DB) DeleteReaction(guildID, userID, messageID, emoji string) error {
+	_, err := d.conn.Exec(`DELETE FROM reactions WHERE guild_id = ? AND user_id = ? AND message_id = ? AND emoji = ?`,
+		guildID, userID, messageID, emoji)
+	if err != nil {
+		return fmt.Errorf("failed deleting reaction state: %v", err)
+	}
+	return nil
+}
+
+// DeleteReactionsForUser removes every reaction recorded for a user on a message, e.g. when all
+// of that user's reactions are cleared at once.
+func (d *DB) DeleteReactionsForUser(guildID, userID, messageID string) error {
+	_, err := d.conn.Exec(`DELETE FROM reactions WHERE guild_id = ? AND user_id = ? AND message_id = ?`,
+		guildID, userID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed deleting reaction state: %v", err)
+	}
+	return nil
+}
+
+// NextBestReaction returns the highest-priority (lowest Priority value) reaction a user still has
+// on a message, and whether they have one at all.
+func (d *DB) NextBestReaction(guildID, userID, messageID string) (ReactionState, bool, error) {
+	var state ReactionState
+	err := d.conn.Get(&state, `SELECT guild_id, user_id, message_id, emoji, priority, colour FROM reactions
+		WHERE guild_id = ? AND user_id = ? AND message_id = ?
+		ORDER BY priority ASC LIMIT 1`, guildID, userID, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReactionState{}, false, nil
+	}
+	if err != nil {
+		return ReactionState{}, false, fmt.Errorf("failed finding next best reaction: %v", err)
+	}
+	return state, true, nil
+}
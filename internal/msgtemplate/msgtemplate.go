@@ -0,0 +1,44 @@
+// Package msgtemplate resolves the {name}-placeholder templates configured under
+// Config.Discord.Templates against a per-call context, so operators can customise/localise the
+// bot's result messages without recompiling.
+package msgtemplate
+
+import (
+	"io"
+	"strings"
+
+	"github.com/valyala/fasttemplate"
+
+	"rolly/internal/textutil"
+)
+
+// Context supplies the named placeholder values a template may reference (e.g. "nick", "emoji",
+// "colour"), plus the count a "{plural:<plural>:<singular>}" placeholder resolves against.
+type Context struct {
+	Values map[string]string
+	Count  int
+}
+
+// Render resolves every {name} placeholder in tmpl against ctx.Values (an unknown name resolves to
+// an empty string) and every {plural:<plural>:<singular>} placeholder against ctx.Count via
+// textutil.Pluralise. A malformed template (e.g. from a bad manual config.toml edit) is returned
+// unchanged rather than panicking.
+func Render(tmpl string, ctx Context) string {
+	t, err := fasttemplate.NewTemplate(tmpl, "{", "}")
+	if err != nil {
+		return tmpl
+	}
+
+	var b strings.Builder
+	if _, err := t.ExecuteFunc(&b, func(w io.Writer, tag string) (int, error) {
+		if plural, rest, found := strings.Cut(tag, ":"); found && plural == "plural" {
+			pluralForm, singularForm, _ := strings.Cut(rest, ":")
+			return io.WriteString(w, textutil.Pluralise(singularForm, pluralForm, ctx.Count))
+		}
+		return io.WriteString(w, ctx.Values[tag])
+	}); err != nil {
+		return tmpl
+	}
+
+	return b.String()
+}
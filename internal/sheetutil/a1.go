@@ -0,0 +1,158 @@
+// Package sheetutil parses and formats A1 notation, the cell/range addressing scheme used by the
+// Google Sheets API (e.g. `A1`, `C2:D17`, `'My Sheet'!AE2:AF357`).
+package sheetutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RangeTokenPattern splits a space-separated list of A1 ranges into individual tokens, taking care
+// not to split on spaces inside a quoted sheet name (e.g. `'My Sheet'!A1:B2`).
+var RangeTokenPattern = regexp.MustCompile(`'[^']*'![^\s]+|[^\s]+`)
+
+// ParseA1ColumnToInt parses an A1 column string to a zero-based integer offset.
+// For example, 'A' becomes 0, 'B' becomes 1, 'AE' becomes 30, etc.
+func ParseA1ColumnToInt(column string) (int, error) {
+	if column == "" {
+		return 0, errors.New("column string is empty")
+	}
+
+	output := 0
+	runes := []rune(column)
+	multiplier := 0
+	for i := len(runes) - 1; i >= 0; i-- {
+		// Enforce that all characters are alphabetical and uppercase
+		if runes[i] < 'A' || runes[i] > 'Z' {
+			return 0, errors.New("non-uppercase or non-alphabetical character in column string")
+		}
+
+		// Add to the output
+		output += (int(math.Pow(float64(26), float64(multiplier))) * (int(runes[i]-'A') + 1))
+		multiplier++
+	}
+
+	return output - 1, nil
+}
+
+// ToA1Column converts a zero-based column index into spreadsheet column letters (0 -> "A", 26 ->
+// "AA").
+func ToA1Column(x int) string {
+	x++
+	var letters []byte
+	for x > 0 {
+		x--
+		letters = append([]byte{byte('A' + x%26)}, letters...)
+		x /= 26
+	}
+	return string(letters)
+}
+
+// ParsedRange describes a parsed A1-notation range: the sheet it's on (empty for whichever sheet
+// is implicit/default), the zero-based x/y offset of its top-left cell, and its width/height.
+// A dimension left open by the original notation (a whole column like `A:A`, or a whole row like
+// `2:5`) is represented as -1, meaning "to the end of the sheet".
+type ParsedRange struct {
+	Sheet  string
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// sheetPrefixPattern splits an optional leading `Sheet1!`/`'My Sheet'!` off the rest of a range.
+var sheetPrefixPattern = regexp.MustCompile(`^(?:(?:'([^']+)'|([\w ]+))!)?(.+)$`)
+
+// cellRangePattern matches a single cell (`A1`), a bounded cell range (`A1:B2`), or a dangling
+// range missing its end row (`A1:B`) so ParseA1Notation can reject the latter with a specific
+// error rather than falling through to the generic "not a recognised A1 notation range" one.
+var cellRangePattern = regexp.MustCompile(`^([A-Za-z]+)([0-9]+)(?::([A-Za-z]+)([0-9]*))?$`)
+
+// columnRangePattern matches a whole-column range, e.g. `A:A` or `B:D`.
+var columnRangePattern = regexp.MustCompile(`^([A-Za-z]+):([A-Za-z]+)$`)
+
+// rowRangePattern matches a whole-row range, e.g. `2:5`.
+var rowRangePattern = regexp.MustCompile(`^([0-9]+):([0-9]+)$`)
+
+// ParseA1Notation parses the given A1 string, optionally sheet-qualified (`Sheet1!A1:B2`,
+// `'My Sheet'!A1`), into a ParsedRange. Whole-column (`A:A`) and whole-row (`2:5`) ranges are
+// also accepted, with the open dimension represented as -1 in the result. Column letters may be
+// given in either case.
+func ParseA1Notation(_range string) (*ParsedRange, error) {
+	prefixMatch := sheetPrefixPattern.FindStringSubmatch(_range)
+	if prefixMatch == nil {
+		return nil, errors.New("input is not an A1 notation range")
+	}
+	sheet := prefixMatch[1]
+	if sheet == "" {
+		sheet = prefixMatch[2]
+	}
+	rangePart := prefixMatch[3]
+
+	if cellMatch := cellRangePattern.FindStringSubmatch(rangePart); cellMatch != nil {
+		startCol, startRow, endCol, endRow := cellMatch[1], cellMatch[2], cellMatch[3], cellMatch[4]
+
+		x, err := ParseA1ColumnToInt(strings.ToUpper(startCol))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range start column offset: %v", err)
+		}
+
+		y, err := strconv.Atoi(startRow)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range start row offset: %v", err)
+		}
+		y -= 1
+
+		if endCol == "" {
+			// Single cell, no end given at all
+			return &ParsedRange{Sheet: sheet, X: x, Y: y, Width: 1, Height: 1}, nil
+		}
+		if endRow == "" {
+			return nil, fmt.Errorf("%q has a dangling end column with no row number", _range)
+		}
+
+		endX, err := ParseA1ColumnToInt(strings.ToUpper(endCol))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range end column offset: %v", err)
+		}
+		endY, err := strconv.Atoi(endRow)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range end row offset: %v", err)
+		}
+
+		return &ParsedRange{Sheet: sheet, X: x, Y: y, Width: endX - x + 1, Height: endY - y}, nil
+	}
+
+	if columnMatch := columnRangePattern.FindStringSubmatch(rangePart); columnMatch != nil {
+		x, err := ParseA1ColumnToInt(strings.ToUpper(columnMatch[1]))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range start column offset: %v", err)
+		}
+		endX, err := ParseA1ColumnToInt(strings.ToUpper(columnMatch[2]))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range end column offset: %v", err)
+		}
+
+		return &ParsedRange{Sheet: sheet, X: x, Y: 0, Width: endX - x + 1, Height: -1}, nil
+	}
+
+	if rowMatch := rowRangePattern.FindStringSubmatch(rangePart); rowMatch != nil {
+		y, err := strconv.Atoi(rowMatch[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range start row offset: %v", err)
+		}
+		y -= 1
+		endY, err := strconv.Atoi(rowMatch[2])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse range end row offset: %v", err)
+		}
+
+		return &ParsedRange{Sheet: sheet, X: 0, Y: y, Width: -1, Height: endY - y}, nil
+	}
+
+	return nil, fmt.Errorf("%q is not a recognised A1 notation range", rangePart)
+}
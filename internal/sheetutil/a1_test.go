@@ -0,0 +1,102 @@
+package sheetutil
+
+import "testing"
+
+func TestParseA1Notation(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		want       ParsedRange
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{name: "single cell", input: "A1", want: ParsedRange{X: 0, Y: 0, Width: 1, Height: 1}},
+		{name: "lowercase single cell", input: "b3", want: ParsedRange{X: 1, Y: 2, Width: 1, Height: 1}},
+		{name: "bounded range", input: "A1:B2", want: ParsedRange{X: 0, Y: 0, Width: 2, Height: 2}},
+		{name: "sheet-qualified bare name", input: "Sheet1!A1:B2", want: ParsedRange{Sheet: "Sheet1", X: 0, Y: 0, Width: 2, Height: 2}},
+		{name: "sheet-qualified quoted name", input: "'My Sheet'!C2:D3", want: ParsedRange{Sheet: "My Sheet", X: 2, Y: 1, Width: 2, Height: 2}},
+		{name: "whole column", input: "A:A", want: ParsedRange{X: 0, Y: 0, Width: 1, Height: -1}},
+		{name: "whole column range", input: "b:d", want: ParsedRange{X: 1, Y: 0, Width: 3, Height: -1}},
+		{name: "whole row", input: "2:5", want: ParsedRange{X: 0, Y: 1, Width: -1, Height: 4}},
+		{name: "sheet-qualified whole column", input: "Sheet1!A:A", want: ParsedRange{Sheet: "Sheet1", X: 0, Y: 0, Width: 1, Height: -1}},
+		{name: "invalid dangling end column", input: "A1:B", wantErr: true, wantErrMsg: `"A1:B" has a dangling end column with no row number`},
+		{name: "invalid empty", input: "", wantErr: true},
+		{name: "invalid garbage", input: "not a range", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseA1Notation(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseA1Notation(%q) = %+v, want error", tt.input, got)
+				}
+				if tt.wantErrMsg != "" && err.Error() != tt.wantErrMsg {
+					t.Fatalf("ParseA1Notation(%q) error = %q, want %q", tt.input, err.Error(), tt.wantErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseA1Notation(%q) returned unexpected error: %v", tt.input, err)
+			}
+			if *got != tt.want {
+				t.Fatalf("ParseA1Notation(%q) = %+v, want %+v", tt.input, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseA1ColumnToInt(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{input: "A", want: 0},
+		{input: "B", want: 1},
+		{input: "Z", want: 25},
+		{input: "AA", want: 26},
+		{input: "AE", want: 30},
+		{input: "", wantErr: true},
+		{input: "a1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseA1ColumnToInt(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseA1ColumnToInt(%q) = %d, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseA1ColumnToInt(%q) returned unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseA1ColumnToInt(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToA1Column(t *testing.T) {
+	tests := []struct {
+		input int
+		want  string
+	}{
+		{input: 0, want: "A"},
+		{input: 1, want: "B"},
+		{input: 25, want: "Z"},
+		{input: 26, want: "AA"},
+		{input: 30, want: "AE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := ToA1Column(tt.input); got != tt.want {
+				t.Fatalf("ToA1Column(%d) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
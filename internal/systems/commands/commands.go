@@ -0,0 +1,100 @@
+// Package commands owns slash command registration. Every other subsystem calls Register during
+// its own Init to queue up the commands it wants to expose; this package's own Init must run last
+// so that every command is known before it syncs the full set with Discord.
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bwmarrin/discordgo"
+
+	"rolly/internal/config"
+)
+
+// Handler is a slash command's interaction handler, registered alongside its
+// *discordgo.ApplicationCommand via Register.
+type Handler func(session *discordgo.Session, i *discordgo.InteractionCreate)
+
+type entry struct {
+	command *discordgo.ApplicationCommand
+	handler Handler
+}
+
+// registry accumulates every command queued by Register across every subsystem's Init, in the
+// order they were registered, until this package's own Init syncs them all with Discord.
+var registry []entry
+
+// Register queues command and handler to be synced with Discord once Init runs. Subsystems call
+// this from their own Init.
+func Register(command *discordgo.ApplicationCommand, handler Handler) {
+	registry = append(registry, entry{command: command, handler: handler})
+}
+
+// Init registers the generic /help command, then creates every command queued by Register (by
+// this point, every other subsystem has already had its own Init called) with Discord and wires up
+// its handler. Must be the last subsystem Init called.
+func Init(session *discordgo.Session, cfg *config.Config, _ chan<- config.NameColourUpdate) error {
+	Register(&discordgo.ApplicationCommand{
+		Name:        "help",
+		Description: "Prints this help text",
+	}, handleHelp)
+
+	for _, e := range registry {
+		if _, err := session.ApplicationCommandCreate(cfg.Discord.ApplicationID, cfg.Discord.BotServer, e.command); err != nil {
+			return fmt.Errorf("failed registering command \"%s\": %v", e.command.Name, err)
+		}
+
+		session.AddHandler(e.handler)
+
+		fmt.Printf("Registered \"%s\" command\n", e.command.Name)
+	}
+
+	return nil
+}
+
+func handleHelp(session *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !Assert(i, "help") {
+		return
+	}
+
+	err := session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Hey, I'm Rolly! I create roll call messages in a channel you choose, and update the colours of users that react to them in a Google Sheets spreadsheet.\n" +
+				"You can try sending a roll call with `/create`, or use one of the other commands to configure how I work.",
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed responding to interaction: %v\n", err)
+	}
+}
+
+// Assert returns whether the given interaction is an application command and the name of the
+// command matches the one given.
+func Assert(interaction *discordgo.InteractionCreate, name string) bool {
+	return interaction.Type == discordgo.InteractionApplicationCommand && interaction.ApplicationCommandData().Name == name
+}
+
+// Respond responds to the given interaction with the given message.
+func Respond(session *discordgo.Session, interaction *discordgo.Interaction, message *string) {
+	err := session.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: *message,
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed responding to interaction: %v\n", err)
+	}
+}
+
+// Update updates a response to the given interaction with the given message.
+func Update(session *discordgo.Session, interaction *discordgo.Interaction, message *string) {
+	_, err := session.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+		Content: message,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed updating interaction response: %v\n", err)
+	}
+}
@@ -0,0 +1,359 @@
+package reactions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"rolly/internal/config"
+	"rolly/internal/sheetutil"
+	"rolly/internal/systems/commands"
+)
+
+// maxPollOptions bounds how many options a single poll can have, since each one needs its own
+// emoji reaction seeded on the message.
+const maxPollOptions = 10
+
+// registerPollCommands registers /poll and its create/close subcommands, for running reaction-
+// driven polls that optionally mirror their live tally into a sheet range.
+func registerPollCommands() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "poll",
+		Description: "Run a reaction-driven poll",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "create",
+				Description: "Creates a new poll",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "title",
+						Description: "Poll title",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "options",
+						Description: "Comma-separated emoji:text pairs, e.g. \"👍:Yes,👎:No\"",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionChannel,
+						Name:        "channel",
+						Description: "Channel to post the poll in",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "range",
+						Description: "Sheet range to mirror live tallies into, one column per option, e.g. \"Votes!B2:D2\"",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "close",
+				Description: "Closes a poll, freezing its tally",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "id",
+						Description: "Message ID of the poll to close",
+						Required:    true,
+					},
+				},
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "poll") {
+			return
+		}
+
+		subcommand := i.ApplicationCommandData().Options[0]
+		switch subcommand.Name {
+		case "create":
+			handlePollCreate(session, i, subcommand.Options)
+		case "close":
+			handlePollClose(session, i, subcommand.Options)
+		}
+	})
+}
+
+// pollOption is one emoji:text pair parsed out of /poll create's options string.
+type pollOption struct {
+	Emoji string
+	Text  string
+}
+
+// parsePollOptions parses a comma-separated "emoji:text,emoji:text" string into pollOptions,
+// erroring on malformed pairs, duplicate emoji, or too few/many options.
+func parsePollOptions(raw string) ([]pollOption, error) {
+	parts := strings.Split(raw, ",")
+	options := make([]pollOption, 0, len(parts))
+	seen := make(map[string]bool)
+
+	for _, part := range parts {
+		emoji, text, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found || emoji == "" || text == "" {
+			return nil, fmt.Errorf("%q isn't a valid \"emoji:text\" pair", part)
+		}
+		if seen[emoji] {
+			return nil, fmt.Errorf("emoji %s is used for more than one option", emoji)
+		}
+		seen[emoji] = true
+		options = append(options, pollOption{Emoji: emoji, Text: strings.TrimSpace(text)})
+	}
+
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no options given")
+	}
+	if len(options) > maxPollOptions {
+		return nil, fmt.Errorf("a poll can have at most %d options", maxPollOptions)
+	}
+
+	return options, nil
+}
+
+func handlePollCreate(session *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	messageContent := "Creating poll..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't create that poll."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	title := subOption(options, "title").StringValue()
+	optionsRaw := subOption(options, "options").StringValue()
+
+	parsedOptions, err := parsePollOptions(optionsRaw)
+	if err != nil {
+		messageUpdateContent = fmt.Sprintf("I couldn't parse those options: %v", err)
+		return
+	}
+
+	var rangeA1 string
+	if rangeOption := subOption(options, "range"); rangeOption != nil {
+		rangeA1 = rangeOption.StringValue()
+		parsedRange, err := sheetutil.ParseA1Notation(rangeA1)
+		if err != nil {
+			messageUpdateContent = fmt.Sprintf("%q doesn't look like a valid sheet range: %v", rangeA1, err)
+			return
+		}
+		if parsedRange.Width != len(parsedOptions) {
+			messageUpdateContent = fmt.Sprintf("%q is %d column(s) wide, but the poll has %d option(s) - they need to match.", rangeA1, parsedRange.Width, len(parsedOptions))
+			return
+		}
+	}
+
+	var channel *discordgo.Channel
+	if channelOption := subOption(options, "channel"); channelOption != nil {
+		channel = channelOption.ChannelValue(nil)
+	}
+	if channel == nil {
+		var err error
+		channel, err = session.Channel(i.Interaction.ChannelID)
+		if err != nil {
+			messageUpdateContent = "I couldn't get the channel to post the poll in."
+			return
+		}
+	}
+
+	var body strings.Builder
+	body.WriteString(title)
+	for _, option := range parsedOptions {
+		body.WriteString(fmt.Sprintf("\n%s %s", option.Emoji, option.Text))
+	}
+
+	message, err := session.ChannelMessageSend(channel.ID, body.String())
+	if err != nil {
+		messageUpdateContent = "I couldn't send the poll message."
+		return
+	}
+
+	emojis := make([]string, len(parsedOptions))
+	optionText := make([]string, len(parsedOptions))
+	for idx, option := range parsedOptions {
+		emojis[idx] = option.Emoji
+		optionText[idx] = option.Text
+
+		if err := session.MessageReactionAdd(channel.ID, message.ID, option.Emoji); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed adding %s reaction to poll message: %v\n", option.Emoji, err)
+		}
+	}
+
+	poll := &config.Poll{
+		MsgID:        message.ID,
+		ChannelID:    channel.ID,
+		GuildID:      i.GuildID,
+		OwnerID:      i.Member.User.ID,
+		Title:        title,
+		OptionEmojis: emojis,
+		OptionText:   optionText,
+		UserVotes:    make(map[string]string),
+		RangeA1:      rangeA1,
+	}
+	if err := config.GuildStore.PutPoll(poll); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving poll: %v\n", err)
+		return
+	}
+
+	messageUpdateContent = fmt.Sprintf("Created a new poll in <#%s> with ID `%s`.", channel.ID, message.ID)
+}
+
+func handlePollClose(session *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	messageContent := "Closing poll..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't close that poll."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	id := subOption(options, "id").StringValue()
+	poll, err := config.GuildStore.GetPoll(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed getting poll \"%s\": %v\n", id, err)
+		return
+	}
+	if poll == nil || poll.GuildID != i.GuildID {
+		messageUpdateContent = fmt.Sprintf("I don't have a poll tracked with the ID `%s` in this server.", id)
+		return
+	}
+	if poll.Finished {
+		messageUpdateContent = fmt.Sprintf("Poll `%s` is already closed.", id)
+		return
+	}
+
+	for _, emoji := range poll.OptionEmojis {
+		if err := session.MessageReactionRemove(poll.ChannelID, poll.MsgID, emoji, "@me"); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed removing %s reaction from poll %s: %v\n", emoji, poll.MsgID, err)
+		}
+	}
+
+	poll.Finished = true
+	if err := config.GuildStore.PutPoll(poll); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving poll \"%s\": %v\n", id, err)
+		return
+	}
+
+	messageUpdateContent = fmt.Sprintf("Closed poll `%s`. Final tally:\n%s", id, pollTallySummary(poll))
+}
+
+// pollTallyCounts counts poll.UserVotes by option, in the same order as poll.OptionEmojis.
+func pollTallyCounts(poll *config.Poll) []int {
+	counts := make([]int, len(poll.OptionEmojis))
+	for _, emoji := range poll.UserVotes {
+		for idx, optionEmoji := range poll.OptionEmojis {
+			if optionEmoji == emoji {
+				counts[idx]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// pollTallySummary renders poll's current tally as one line per option.
+func pollTallySummary(poll *config.Poll) string {
+	counts := pollTallyCounts(poll)
+	lines := make([]string, len(poll.OptionEmojis))
+	for idx := range poll.OptionEmojis {
+		lines[idx] = fmt.Sprintf("%s %s: %d", poll.OptionEmojis[idx], poll.OptionText[idx], counts[idx])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendPollTally pushes poll's current tally to pollTallyQueue for the sheets subsystem to write
+// out, if the poll has a target range configured.
+func sendPollTally(poll *config.Poll) {
+	if poll.RangeA1 == "" || pollTallyQueue == nil {
+		return
+	}
+
+	pollTallyQueue <- config.PollTallyUpdate{
+		GuildID: poll.GuildID,
+		RangeA1: poll.RangeA1,
+		Counts:  pollTallyCounts(poll),
+	}
+}
+
+// handlePollReactionAdd records a user's vote (one option at a time - a later react overrides an
+// earlier one) and pushes the updated tally out.
+func handlePollReactionAdd(s *discordgo.Session, e *discordgo.MessageReactionAdd) {
+	if s.State.User.ID == e.Member.User.ID {
+		return
+	}
+
+	poll, err := config.GuildStore.GetPoll(e.MessageID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed getting poll for message \"%s\": %v\n", e.MessageID, err)
+		return
+	}
+	if poll == nil || poll.Finished {
+		return
+	}
+
+	matched := false
+	for _, emoji := range poll.OptionEmojis {
+		if emoji == e.Emoji.Name {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return
+	}
+
+	poll.UserVotes[e.Member.User.ID] = e.Emoji.Name
+	if err := config.GuildStore.PutPoll(poll); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving poll \"%s\": %v\n", poll.MsgID, err)
+	}
+
+	sendPollTally(poll)
+}
+
+// handlePollReactionRemove clears a user's vote for the removed emoji, if that's what they'd
+// voted with, and pushes the updated tally out.
+func handlePollReactionRemove(s *discordgo.Session, e *discordgo.MessageReactionRemove) {
+	poll, err := config.GuildStore.GetPoll(e.MessageID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed getting poll for message \"%s\": %v\n", e.MessageID, err)
+		return
+	}
+	if poll == nil || poll.Finished {
+		return
+	}
+
+	if poll.UserVotes[e.UserID] != e.Emoji.Name {
+		return
+	}
+
+	delete(poll.UserVotes, e.UserID)
+	if err := config.GuildStore.PutPoll(poll); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving poll \"%s\": %v\n", poll.MsgID, err)
+	}
+
+	sendPollTally(poll)
+}
+
+// handlePollReactionRemoveAll clears every vote on a poll and pushes the updated tally out.
+// MESSAGE_REACTION_REMOVE_ALL has no user_id (every reaction on the message was cleared, not just
+// one user's), so there's no single voter's vote to remove here.
+func handlePollReactionRemoveAll(s *discordgo.Session, e *discordgo.MessageReactionRemoveAll) {
+	poll, err := config.GuildStore.GetPoll(e.MessageID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed getting poll for message \"%s\": %v\n", e.MessageID, err)
+		return
+	}
+	if poll == nil || poll.Finished {
+		return
+	}
+
+	poll.UserVotes = make(map[string]string)
+	if err := config.GuildStore.PutPoll(poll); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving poll \"%s\": %v\n", poll.MsgID, err)
+	}
+
+	sendPollTally(poll)
+}
@@ -0,0 +1,441 @@
+package reactions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"rolly/internal/config"
+	"rolly/internal/systems/commands"
+	"rolly/internal/systems/sheets"
+	"rolly/internal/textutil"
+)
+
+// registerReactionCommands registers /reaction and its add/list/remove/exclude/unexclude
+// subcommands, for managing ReactionRules at runtime.
+func registerReactionCommands() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "reaction",
+		Description: "Manage regex/glob-based reaction rules",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Adds a new reaction rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match-type",
+						Description: "How to interpret the match pattern",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "literal", Value: string(config.MatchLiteral)},
+							{Name: "glob", Value: string(config.MatchGlob)},
+							{Name: "regex", Value: string(config.MatchRegex)},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match",
+						Description: "Emoji name/ID (literal), glob pattern, or regular expression to match",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "colour",
+						Description: "Hex or named colour to set on a match",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "priority",
+						Description: "Lower numbers win when more than one rule matches",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Lists the reaction rules configured for this server",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Removes a reaction rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match",
+						Description: "Match pattern of the rule to remove",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "exclude",
+				Description: "Excludes a channel or role from triggering a reaction rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match",
+						Description: "Match pattern of the rule to exclude from",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionChannel,
+						Name:        "channel",
+						Description: "Channel to exclude",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "role",
+						Description: "Role to exclude",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "unexclude",
+				Description: "Removes a channel or role exclusion from a reaction rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match",
+						Description: "Match pattern of the rule to remove the exclusion from",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionChannel,
+						Name:        "channel",
+						Description: "Channel to stop excluding",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "role",
+						Description: "Role to stop excluding",
+						Required:    false,
+					},
+				},
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "reaction") {
+			return
+		}
+
+		subcommand := i.ApplicationCommandData().Options[0]
+		switch subcommand.Name {
+		case "add":
+			handleReactionAdd(session, i, subcommand.Options)
+		case "list":
+			handleReactionList(session, i)
+		case "remove":
+			handleReactionRemove(session, i, subcommand.Options)
+		case "exclude":
+			handleReactionExclude(session, i, subcommand.Options, true)
+		case "unexclude":
+			handleReactionExclude(session, i, subcommand.Options, false)
+		}
+	})
+}
+
+// subOption returns the named option out of a subcommand's option list, or nil if it's absent.
+func subOption(options []*discordgo.ApplicationCommandInteractionDataOption, name string) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Name == name {
+			return option
+		}
+	}
+	return nil
+}
+
+func handleReactionAdd(session *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	messageContent := "Adding reaction rule..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't add that reaction rule."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	matchType := config.MatchType(subOption(options, "match-type").StringValue())
+	match := subOption(options, "match").StringValue()
+	colour := subOption(options, "colour").StringValue()
+	priority := int(subOption(options, "priority").IntValue())
+
+	if _, err := sheets.ParseColour(colour); err != nil {
+		messageUpdateContent = fmt.Sprintf("`%s` doesn't look like a valid colour.", colour)
+		return
+	}
+
+	if matchType == config.MatchRegex {
+		if _, err := regexp.Compile(match); err != nil {
+			messageUpdateContent = fmt.Sprintf("`%s` isn't a valid regular expression: %v", match, err)
+			return
+		}
+	} else if matchType == config.MatchGlob {
+		if _, err := filepath.Match(match, ""); err != nil {
+			messageUpdateContent = fmt.Sprintf("`%s` isn't a valid glob pattern: %v", match, err)
+			return
+		}
+	} else if matchType != config.MatchLiteral {
+		messageUpdateContent = fmt.Sprintf("`%s` isn't a recognised match type.", matchType)
+		return
+	}
+
+	guildConfig := config.GuildConfigOrDefault(i.GuildID)
+	guildConfig.ReactionRules = append(guildConfig.ReactionRules, config.ReactionRule{
+		MatchType: matchType,
+		Match:     match,
+		Colour:    colour,
+		Priority:  priority,
+	})
+	if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+		return
+	}
+
+	messageUpdateContent = fmt.Sprintf("Added a %s rule matching `%s`, setting colour to `%s` at priority %d.", matchType, match, colour, priority)
+}
+
+func handleReactionList(session *discordgo.Session, i *discordgo.InteractionCreate) {
+	messageContent := "Getting reaction rules..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't get the reaction rules."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	rules := config.GuildConfigOrDefault(i.GuildID).ReactionRules
+	if len(rules) == 0 {
+		messageUpdateContent = "I don't have any reaction rules configured in this server. Add one with `/reaction add`."
+		return
+	}
+
+	lines := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		line := fmt.Sprintf("`%s` (%s) -> `%s`, priority %d", rule.Match, rule.MatchType, rule.Colour, rule.Priority)
+		if len(rule.ExcludedChannels) > 0 {
+			line += fmt.Sprintf(", excluded channels: %s", strings.Join(channelMentions(rule.ExcludedChannels), ", "))
+		}
+		if len(rule.ExcludedRoles) > 0 {
+			line += fmt.Sprintf(", excluded roles: %s", strings.Join(roleMentions(rule.ExcludedRoles), ", "))
+		}
+		lines = append(lines, line)
+	}
+
+	messageUpdateContent = fmt.Sprintf("Here %s the %s configured in this server:\n%s", textutil.Pluralise("is", "are", len(lines)), textutil.Pluralise("reaction rule", "reaction rules", len(lines)), strings.Join(lines, "\n"))
+}
+
+func handleReactionRemove(session *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	messageContent := "Removing reaction rule..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't remove that reaction rule."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	match := subOption(options, "match").StringValue()
+
+	guildConfig := config.GuildConfigOrDefault(i.GuildID)
+	remaining := make([]config.ReactionRule, 0, len(guildConfig.ReactionRules))
+	removed := false
+	for _, rule := range guildConfig.ReactionRules {
+		if rule.Match == match {
+			removed = true
+			continue
+		}
+		remaining = append(remaining, rule)
+	}
+
+	if !removed {
+		messageUpdateContent = fmt.Sprintf("I don't have a reaction rule matching `%s` in this server.", match)
+		return
+	}
+
+	guildConfig.ReactionRules = remaining
+	if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+		return
+	}
+
+	messageUpdateContent = fmt.Sprintf("Removed the reaction rule matching `%s`.", match)
+}
+
+func handleReactionExclude(session *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, exclude bool) {
+	messageContent := "Updating reaction rule exclusions..."
+	commands.Respond(session, i.Interaction, &messageContent)
+
+	messageUpdateContent := "I couldn't update that reaction rule's exclusions."
+	defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+	match := subOption(options, "match").StringValue()
+	channelOption := subOption(options, "channel")
+	roleOption := subOption(options, "role")
+
+	if channelOption == nil && roleOption == nil {
+		messageUpdateContent = "Give me a channel or a role to exclude (or stop excluding)."
+		return
+	}
+
+	guildConfig := config.GuildConfigOrDefault(i.GuildID)
+	ruleIndex := -1
+	for index, rule := range guildConfig.ReactionRules {
+		if rule.Match == match {
+			ruleIndex = index
+			break
+		}
+	}
+	if ruleIndex == -1 {
+		messageUpdateContent = fmt.Sprintf("I don't have a reaction rule matching `%s` in this server.", match)
+		return
+	}
+
+	rule := &guildConfig.ReactionRules[ruleIndex]
+	if channelOption != nil {
+		channelID := channelOption.ChannelValue(nil).ID
+		if exclude {
+			rule.ExcludedChannels = appendUnique(rule.ExcludedChannels, channelID)
+		} else {
+			rule.ExcludedChannels = removeString(rule.ExcludedChannels, channelID)
+		}
+	}
+	if roleOption != nil {
+		roleID := roleOption.RoleValue(session, i.GuildID).ID
+		if exclude {
+			rule.ExcludedRoles = appendUnique(rule.ExcludedRoles, roleID)
+		} else {
+			rule.ExcludedRoles = removeString(rule.ExcludedRoles, roleID)
+		}
+	}
+
+	if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+		return
+	}
+
+	if exclude {
+		messageUpdateContent = fmt.Sprintf("Updated exclusions for the reaction rule matching `%s`.", match)
+	} else {
+		messageUpdateContent = fmt.Sprintf("Removed exclusions for the reaction rule matching `%s`.", match)
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func removeString(values []string, value string) []string {
+	out := make([]string, 0, len(values))
+	for _, existing := range values {
+		if existing != value {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+func channelMentions(channelIDs []string) []string {
+	out := make([]string, len(channelIDs))
+	for i, id := range channelIDs {
+		out[i] = fmt.Sprintf("<#%s>", id)
+	}
+	return out
+}
+
+func roleMentions(roleIDs []string) []string {
+	out := make([]string, len(roleIDs))
+	for i, id := range roleIDs {
+		out[i] = fmt.Sprintf("<@&%s>", id)
+	}
+	return out
+}
+
+// guildReactionRules returns every rule that can produce a colour for this guild -- the legacy
+// exact-match ReactionColours entries plus the configured ReactionRules -- sorted by ascending
+// Priority so the first matching, non-excluded rule is the one that should win.
+func guildReactionRules(guildConfig *config.GuildConfig) []config.ReactionRule {
+	rules := make([]config.ReactionRule, 0, len(guildConfig.ReactionColours)+len(guildConfig.ReactionRules))
+	for emoji, colourPriority := range guildConfig.ReactionColours {
+		rules = append(rules, config.ReactionRule{
+			MatchType: config.MatchLiteral,
+			Match:     emoji,
+			Colour:    colourPriority.Colour,
+			Priority:  colourPriority.Priority,
+		})
+	}
+	rules = append(rules, guildConfig.ReactionRules...)
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+
+	return rules
+}
+
+// matchReactionRule reports whether the given emoji name/ID satisfies rule's Match pattern under
+// its MatchType.
+func matchReactionRule(rule config.ReactionRule, emojiName string, emojiID string) bool {
+	switch rule.MatchType {
+	case config.MatchGlob:
+		if ok, err := filepath.Match(rule.Match, emojiName); err == nil && ok {
+			return true
+		}
+		ok, err := filepath.Match(rule.Match, emojiID)
+		return err == nil && ok
+	case config.MatchRegex:
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(emojiName) || re.MatchString(emojiID)
+	default: // config.MatchLiteral
+		return rule.Match == emojiName || (emojiID != "" && rule.Match == emojiID)
+	}
+}
+
+// reactionRuleExcluded reports whether rule shouldn't apply because channelID or one of roleIDs
+// is in its exclusion lists.
+func reactionRuleExcluded(rule config.ReactionRule, channelID string, roleIDs []string) bool {
+	for _, excluded := range rule.ExcludedChannels {
+		if excluded == channelID {
+			return true
+		}
+	}
+	for _, excludedRole := range rule.ExcludedRoles {
+		for _, roleID := range roleIDs {
+			if excludedRole == roleID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findReactionColour returns the colour and priority of the highest-priority rule (ReactionColours
+// entry or ReactionRule) that matches the given emoji in the given channel for a reactor with the
+// given roles, skipping any rule the channel or roles are excluded from, and whether any rule
+// matched at all.
+func findReactionColour(guildConfig *config.GuildConfig, emojiName string, emojiID string, channelID string, roleIDs []string) (config.ColourPriority, bool) {
+	for _, rule := range guildReactionRules(guildConfig) {
+		if !matchReactionRule(rule, emojiName, emojiID) {
+			continue
+		}
+		if reactionRuleExcluded(rule, channelID, roleIDs) {
+			continue
+		}
+		return config.ColourPriority{Colour: rule.Colour, Priority: rule.Priority}, true
+	}
+	return config.ColourPriority{}, false
+}
@@ -0,0 +1,120 @@
+package reactions
+
+import (
+	"reflect"
+	"testing"
+
+	"rolly/internal/config"
+)
+
+func TestGuildReactionRules(t *testing.T) {
+	guildConfig := &config.GuildConfig{
+		ReactionColours: map[string]config.ColourPriority{
+			"✅": {Colour: "00ff00", Priority: 2},
+		},
+		ReactionRules: []config.ReactionRule{
+			{MatchType: config.MatchLiteral, Match: "❌", Colour: "ff0000", Priority: 1},
+		},
+	}
+
+	got := guildReactionRules(guildConfig)
+	want := []config.ReactionRule{
+		{MatchType: config.MatchLiteral, Match: "❌", Colour: "ff0000", Priority: 1},
+		{MatchType: config.MatchLiteral, Match: "✅", Colour: "00ff00", Priority: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("guildReactionRules() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMatchReactionRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      config.ReactionRule
+		emojiName string
+		emojiID   string
+		want      bool
+	}{
+		{name: "literal match by name", rule: config.ReactionRule{MatchType: config.MatchLiteral, Match: "✅"}, emojiName: "✅", want: true},
+		{name: "literal match by ID", rule: config.ReactionRule{MatchType: config.MatchLiteral, Match: "123"}, emojiName: "custom", emojiID: "123", want: true},
+		{name: "literal mismatch", rule: config.ReactionRule{MatchType: config.MatchLiteral, Match: "✅"}, emojiName: "❌", want: false},
+		{name: "literal empty ID never matches empty match", rule: config.ReactionRule{MatchType: config.MatchLiteral, Match: ""}, emojiName: "x", emojiID: "", want: false},
+		{name: "glob match by name", rule: config.ReactionRule{MatchType: config.MatchGlob, Match: "thumbs*"}, emojiName: "thumbsup", want: true},
+		{name: "glob mismatch by name", rule: config.ReactionRule{MatchType: config.MatchGlob, Match: "thumbs*"}, emojiName: "wave", want: false},
+		{name: "glob match by ID", rule: config.ReactionRule{MatchType: config.MatchGlob, Match: "99*"}, emojiName: "custom", emojiID: "998877", want: true},
+		{name: "regex match by name", rule: config.ReactionRule{MatchType: config.MatchRegex, Match: "^thumb(s)?up$"}, emojiName: "thumbsup", want: true},
+		{name: "regex mismatch by name", rule: config.ReactionRule{MatchType: config.MatchRegex, Match: "^thumb(s)?up$"}, emojiName: "thumbsdown", want: false},
+		{name: "regex match by ID", rule: config.ReactionRule{MatchType: config.MatchRegex, Match: "^[0-9]+$"}, emojiName: "custom", emojiID: "123456", want: true},
+		{name: "invalid regex never matches", rule: config.ReactionRule{MatchType: config.MatchRegex, Match: "("}, emojiName: "custom", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchReactionRule(tt.rule, tt.emojiName, tt.emojiID); got != tt.want {
+				t.Fatalf("matchReactionRule(%+v, %q, %q) = %v, want %v", tt.rule, tt.emojiName, tt.emojiID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactionRuleExcluded(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      config.ReactionRule
+		channelID string
+		roleIDs   []string
+		want      bool
+	}{
+		{name: "no exclusions", rule: config.ReactionRule{}, channelID: "chan1", roleIDs: []string{"role1"}, want: false},
+		{name: "channel excluded", rule: config.ReactionRule{ExcludedChannels: []string{"chan1"}}, channelID: "chan1", want: true},
+		{name: "channel not excluded", rule: config.ReactionRule{ExcludedChannels: []string{"chan1"}}, channelID: "chan2", want: false},
+		{name: "role excluded", rule: config.ReactionRule{ExcludedRoles: []string{"role1"}}, roleIDs: []string{"role1", "role2"}, want: true},
+		{name: "role not excluded", rule: config.ReactionRule{ExcludedRoles: []string{"role1"}}, roleIDs: []string{"role2"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reactionRuleExcluded(tt.rule, tt.channelID, tt.roleIDs); got != tt.want {
+				t.Fatalf("reactionRuleExcluded(%+v, %q, %v) = %v, want %v", tt.rule, tt.channelID, tt.roleIDs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindReactionColour(t *testing.T) {
+	guildConfig := &config.GuildConfig{
+		ReactionColours: map[string]config.ColourPriority{
+			"✅": {Colour: "00ff00", Priority: 2},
+		},
+		ReactionRules: []config.ReactionRule{
+			{MatchType: config.MatchGlob, Match: "thumbs*", Colour: "0000ff", Priority: 1, ExcludedChannels: []string{"excluded-chan"}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		emojiName    string
+		emojiID      string
+		channelID    string
+		roleIDs      []string
+		wantPriority config.ColourPriority
+		wantFound    bool
+	}{
+		{name: "glob rule matches", emojiName: "thumbsup", channelID: "chan1", wantPriority: config.ColourPriority{Colour: "0000ff", Priority: 1}, wantFound: true},
+		{name: "legacy ReactionColours entry matches", emojiName: "✅", channelID: "chan1", wantPriority: config.ColourPriority{Colour: "00ff00", Priority: 2}, wantFound: true},
+		{name: "excluded channel skips the rule", emojiName: "thumbsup", channelID: "excluded-chan", wantFound: false},
+		{name: "no match at all", emojiName: "nope", channelID: "chan1", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := findReactionColour(guildConfig, tt.emojiName, tt.emojiID, tt.channelID, tt.roleIDs)
+			if found != tt.wantFound {
+				t.Fatalf("findReactionColour() found = %v, want %v", found, tt.wantFound)
+			}
+			if found && got != tt.wantPriority {
+				t.Fatalf("findReactionColour() = %+v, want %+v", got, tt.wantPriority)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+// Package reactions owns roll call and poll creation/lifecycle, reaction-rule matching, and the
+// Discord reaction event handlers that turn a react into a NameColourUpdate or a poll tally.
+package reactions
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"rolly/internal/config"
+	"rolly/internal/db"
+	"rolly/internal/msgtemplate"
+	"rolly/internal/systems/commands"
+)
+
+// database is the reaction-state and outbox store, injected via SetDB before Init runs.
+var database *db.DB
+
+// SetDB injects the database this subsystem uses for reaction state and the pending-update
+// outbox. Must be called before Init, following the same convention as sheets.SetConfigPath.
+func SetDB(d *db.DB) {
+	database = d
+}
+
+// pollTallyQueue is where poll vote tallies are sent for the sheets subsystem to write out,
+// injected via SetPollTallyQueue before Init runs, same as database.
+var pollTallyQueue chan<- config.PollTallyUpdate
+
+// SetPollTallyQueue injects the channel poll tally updates are sent on. Must be called before
+// Init, following the same convention as SetDB.
+func SetPollTallyQueue(queue chan<- config.PollTallyUpdate) {
+	pollTallyQueue = queue
+}
+
+// Init registers this subsystem's commands and reaction event handlers, replays any pending
+// updates left over from a previous run, then resumes tracking any roll calls that were still
+// open when the bot last shut down.
+func Init(session *discordgo.Session, cfg *config.Config, updateQueue chan<- config.NameColourUpdate) error {
+	registerCreateCommand()
+	registerRollCallCommands()
+	registerReactionCommands()
+	registerPollCommands()
+	registerReactionHandlers(session, updateQueue)
+
+	if err := replayOutbox(updateQueue); err != nil {
+		return err
+	}
+
+	resumeRollCalls(session)
+
+	return nil
+}
+
+// replayOutbox re-sends any pending updates that were durably recorded but never made it onto
+// updateQueue before the bot last stopped (e.g. a crash between enqueuing and sending).
+func replayOutbox(updateQueue chan<- config.NameColourUpdate) error {
+	entries, err := database.ListOutbox()
+	if err != nil {
+		return fmt.Errorf("failed listing outbox entries to replay: %v", err)
+	}
+
+	for _, entry := range entries {
+		updateQueue <- config.NameColourUpdate{
+			GuildID:  entry.GuildID,
+			Name:     entry.Name,
+			Colour:   entry.Colour,
+			Priority: entry.Priority,
+		}
+		if err := database.DeleteOutbox(entry.ID); err != nil {
+			return fmt.Errorf("failed clearing replayed outbox entry %d: %v", entry.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// enqueueUpdate durably records update in the outbox, sends it on updateQueue, then clears the
+// outbox entry now that the handoff has succeeded. This way a crash between computing an update
+// and the sheet queue picking it up doesn't silently lose it.
+func enqueueUpdate(updateQueue chan<- config.NameColourUpdate, update config.NameColourUpdate) {
+	id, err := database.EnqueueOutbox(update.GuildID, update.Name, update.Colour, update.Priority)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed enqueuing outbox entry for %s: %v\n", update.Name, err)
+		updateQueue <- update
+		return
+	}
+
+	updateQueue <- update
+
+	if err := database.DeleteOutbox(id); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed clearing outbox entry %d for %s: %v\n", id, update.Name, err)
+	}
+}
+
+// registerCreateCommand registers /create, which sends a new roll call message and starts
+// tracking reactions on it.
+func registerCreateCommand() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "create",
+		Description: "Creates a new roll call message",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionChannel,
+				Name:        "channel",
+				Description: "Channel to create the roll call in",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "message",
+				Description: "Message to use in the roll call",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "expires",
+				Description: "Optional duration after which I'll remove my reactions and close the roll call (e.g. `1h`, `30m`)",
+				Required:    false,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "create") {
+			return
+		}
+
+		// Parse the options we've been given
+		var channel *discordgo.Channel
+		var rollCallMessage string
+		var expiresValue string
+		for _, option := range i.Interaction.ApplicationCommandData().Options {
+			switch option.Name {
+			case "channel":
+				channel = option.ChannelValue(nil)
+			case "message":
+				rollCallMessage = option.StringValue()
+			case "expires":
+				expiresValue = option.StringValue()
+			}
+		}
+
+		if rollCallMessage == "" {
+			rollCallMessage = "Roll call!"
+		}
+
+		guildConfig := config.GuildConfigOrDefault(i.GuildID)
+
+		if channel == nil {
+			var err error
+			channelID := guildConfig.RollCallChannelID
+			if channelID == "" {
+				channelID = i.Interaction.ChannelID
+			}
+			channel, err = session.Channel(channelID)
+
+			if err != nil {
+				messageContent := fmt.Sprintf("I couldn't get the channel to send the roll call in. This is the message you gave me: `%s`", rollCallMessage)
+				commands.Respond(session, i.Interaction, &messageContent)
+				return
+			}
+		}
+
+		channelName := fmt.Sprintf("<#%s>", channel.ID)
+		messageContent := fmt.Sprintf("Creating a new roll call in %s...", channelName)
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := fmt.Sprintf("Created a new roll call in %s with the following message: `%s`", channelName, rollCallMessage)
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		message, err := session.ChannelMessageSend(channel.ID, rollCallMessage)
+		if err != nil {
+			messageUpdateContent = fmt.Sprintf("I couldn't make the roll call message. This is the message you gave me: `%s`", rollCallMessage)
+			return
+		}
+
+		for emoji := range guildConfig.ReactionColours {
+			err = session.MessageReactionAdd(channel.ID, message.ID, emoji)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed adding %v emoji to roll call message: %v\n", emoji, err)
+				messageUpdateContent = fmt.Sprintf("Created a new roll call in %s with the following message: `%s`.\nI couldn't add one or more emoji to the roll call message though.", rollCallMessage, channelName)
+			}
+		}
+
+		rollCall := &config.RollCall{
+			MessageID:   message.ID,
+			ChannelID:   channel.ID,
+			GuildID:     i.GuildID,
+			CreatorID:   i.Member.User.ID,
+			CreatedAt:   time.Now(),
+			UserColours: make(map[string]string),
+		}
+
+		if expiresValue != "" {
+			duration, err := time.ParseDuration(expiresValue)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid expires duration from create: %v\n", err)
+				messageUpdateContent = fmt.Sprintf("Created the roll call, but `%s` doesn't look like a valid duration (try something like `1h30m`), so it won't auto-close.", expiresValue)
+			} else {
+				expiresAt := time.Now().Add(duration)
+				rollCall.ExpiresAt = &expiresAt
+			}
+		}
+
+		if err := config.GuildStore.PutRollCall(rollCall); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving roll call: %v\n", err)
+			return
+		}
+		scheduleRollCallExpiry(session, rollCall)
+	})
+}
+
+// registerReactionHandlers wires up the three Discord reaction events that drive
+// NameColourUpdates: a react, a single react removed, and all reacts removed.
+func registerReactionHandlers(session *discordgo.Session, updateQueue chan<- config.NameColourUpdate) {
+	session.AddHandler(func(s *discordgo.Session, e *discordgo.MessageReactionAdd) {
+		if session.State.User.ID == e.Member.User.ID {
+			return
+		}
+
+		rollCall, err := config.GuildStore.GetRollCall(e.MessageID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call for message \"%s\": %v\n", e.MessageID, err)
+			return
+		}
+		if rollCall == nil {
+			handlePollReactionAdd(s, e)
+			return
+		}
+		if rollCall.Closed {
+			return
+		}
+
+		value, exists := findReactionColour(config.GuildConfigOrDefault(e.GuildID), e.Emoji.Name, e.Emoji.ID, e.ChannelID, e.Member.Roles)
+
+		if exists {
+			fmt.Println(msgtemplate.Render(config.CurrentTemplates().OnReactAdd, msgtemplate.Context{
+				Values: map[string]string{
+					"nick":   e.Member.Nick,
+					"emoji":  e.MessageReaction.Emoji.Name,
+					"colour": value.Colour,
+				},
+			}))
+
+			rollCall.UserColours[e.Member.User.ID] = value.Colour
+			if err := config.GuildStore.PutRollCall(rollCall); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed saving roll call \"%s\": %v\n", rollCall.MessageID, err)
+			}
+
+			state := db.ReactionState{
+				GuildID:   e.GuildID,
+				UserID:    e.Member.User.ID,
+				MessageID: e.MessageID,
+				Emoji:     e.Emoji.Name,
+				Priority:  value.Priority,
+				Colour:    value.Colour,
+			}
+			if err := database.UpsertReaction(state); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed recording reaction state for %s: %v\n", e.Member.Nick, err)
+			}
+
+			enqueueUpdate(updateQueue, config.NameColourUpdate{
+				GuildID:  e.GuildID,
+				Name:     e.Member.Nick,
+				Colour:   value.Colour,
+				Priority: value.Priority,
+			})
+		} else {
+			fmt.Fprintf(os.Stderr, "%s reacted with unsupported emoji '%s'\n", e.Member.Nick, e.MessageReaction.Emoji.Name)
+			return
+		}
+	})
+
+	session.AddHandler(func(s *discordgo.Session, e *discordgo.MessageReactionRemove) {
+		rollCall, err := config.GuildStore.GetRollCall(e.MessageID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call for message \"%s\": %v\n", e.MessageID, err)
+			return
+		}
+		if rollCall == nil {
+			handlePollReactionRemove(s, e)
+			return
+		}
+		if rollCall.Closed {
+			return
+		}
+
+		member, err := s.GuildMember(e.GuildID, e.UserID)
+		if err != nil {
+			return
+		}
+
+		if err := database.DeleteReaction(e.GuildID, member.User.ID, e.MessageID, e.Emoji.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed removing reaction state for %s: %v\n", member.Nick, err)
+		}
+
+		next, found, err := database.NextBestReaction(e.GuildID, member.User.ID, e.MessageID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed finding next best reaction for %s: %v\n", member.Nick, err)
+			return
+		}
+
+		matchedColour := "FFFFFF" // Default to white
+		matchedPriority := config.ResetPriority
+		if found {
+			matchedColour = next.Colour
+			matchedPriority = next.Priority
+			fmt.Println(msgtemplate.Render(config.CurrentTemplates().OnReactRemoveKeep, msgtemplate.Context{
+				Values: map[string]string{
+					"nick":       member.Nick,
+					"emoji":      e.MessageReaction.Emoji.Name,
+					"next_emoji": next.Emoji,
+					"colour":     matchedColour,
+				},
+			}))
+		} else {
+			fmt.Println(msgtemplate.Render(config.CurrentTemplates().OnReactRemove, msgtemplate.Context{
+				Values: map[string]string{
+					"nick":   member.Nick,
+					"emoji":  e.MessageReaction.Emoji.Name,
+					"colour": matchedColour,
+				},
+			}))
+		}
+
+		if found {
+			rollCall.UserColours[member.User.ID] = matchedColour
+		} else {
+			delete(rollCall.UserColours, member.User.ID)
+		}
+		if err := config.GuildStore.PutRollCall(rollCall); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving roll call \"%s\": %v\n", rollCall.MessageID, err)
+		}
+
+		enqueueUpdate(updateQueue, config.NameColourUpdate{
+			GuildID:  e.GuildID,
+			Name:     member.Nick,
+			Colour:   matchedColour,
+			Priority: matchedPriority,
+		})
+	})
+
+	session.AddHandler(func(s *discordgo.Session, e *discordgo.MessageReactionRemoveAll) {
+		rollCall, err := config.GuildStore.GetRollCall(e.MessageID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call for message \"%s\": %v\n", e.MessageID, err)
+			return
+		}
+		if rollCall == nil {
+			handlePollReactionRemoveAll(s, e)
+			return
+		}
+		if rollCall.Closed {
+			return
+		}
+
+		user, err := s.User(e.UserID)
+		if err != nil {
+			return
+		}
+
+		if err := database.DeleteReactionsForUser(e.GuildID, user.ID, e.MessageID); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed removing reaction state for %s: %v\n", user.Username, err)
+		}
+
+		fmt.Println(msgtemplate.Render(config.CurrentTemplates().OnReactRemoveAll, msgtemplate.Context{
+			Values: map[string]string{"nick": user.Username},
+		}))
+
+		delete(rollCall.UserColours, user.ID)
+		if err := config.GuildStore.PutRollCall(rollCall); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving roll call \"%s\": %v\n", rollCall.MessageID, err)
+		}
+
+		enqueueUpdate(updateQueue, config.NameColourUpdate{
+			GuildID:  e.GuildID,
+			Name:     user.Username,
+			Colour:   "FFFFFF",
+			Priority: config.ResetPriority,
+		})
+	})
+}
@@ -0,0 +1,236 @@
+package reactions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"rolly/internal/config"
+	"rolly/internal/systems/commands"
+	"rolly/internal/textutil"
+)
+
+// registerRollCallCommands registers the slash commands for managing the lifecycle of persisted
+// roll calls: /list, /close, /reopen.
+func registerRollCallCommands() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "list",
+		Description: "Lists the roll calls I'm tracking in this server",
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "list") {
+			return
+		}
+
+		messageContent := "Getting roll calls..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't get the roll call list."
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		rollCalls, err := config.GuildStore.ListRollCalls()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed listing roll calls: %v\n", err)
+			return
+		}
+
+		var lines []string
+		for _, rollCall := range rollCalls {
+			if rollCall.GuildID != i.GuildID {
+				continue
+			}
+
+			status := "open"
+			if rollCall.Closed {
+				status = "closed"
+			}
+			line := fmt.Sprintf("`%s` in <#%s>, created <t:%d:R>, %s", rollCall.MessageID, rollCall.ChannelID, rollCall.CreatedAt.Unix(), status)
+			if rollCall.ExpiresAt != nil {
+				line += fmt.Sprintf(", expires <t:%d:R>", rollCall.ExpiresAt.Unix())
+			}
+			lines = append(lines, line)
+		}
+
+		if len(lines) == 0 {
+			messageUpdateContent = "I'm not tracking any roll calls in this server yet. Create one with `/create`."
+		} else {
+			messageUpdateContent = fmt.Sprintf("Here %s the %s I'm tracking in this server:\n%s", textutil.Pluralise("is", "are", len(lines)), textutil.Pluralise("roll call", "roll calls", len(lines)), strings.Join(lines, "\n"))
+		}
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "close",
+		Description: "Stops tracking reactions on a roll call and removes my reactions from it",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "id",
+				Description: "Message ID of the roll call to close",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "close") {
+			return
+		}
+
+		messageContent := "Closing roll call..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't close that roll call."
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		id := i.ApplicationCommandData().Options[0].StringValue()
+		rollCall, err := config.GuildStore.GetRollCall(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call \"%s\": %v\n", id, err)
+			return
+		}
+		if rollCall == nil || rollCall.GuildID != i.GuildID {
+			messageUpdateContent = fmt.Sprintf("I don't have a roll call tracked with the ID `%s` in this server.", id)
+			return
+		}
+
+		if err := closeRollCall(session, rollCall); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed closing roll call \"%s\": %v\n", id, err)
+			return
+		}
+
+		messageUpdateContent = fmt.Sprintf("Closed the roll call `%s`.", id)
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "reopen",
+		Description: "Resumes tracking reactions on a previously closed roll call",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "id",
+				Description: "Message ID of the roll call to reopen",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "reopen") {
+			return
+		}
+
+		messageContent := "Reopening roll call..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't reopen that roll call."
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		id := i.ApplicationCommandData().Options[0].StringValue()
+		rollCall, err := config.GuildStore.GetRollCall(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call \"%s\": %v\n", id, err)
+			return
+		}
+		if rollCall == nil || rollCall.GuildID != i.GuildID {
+			messageUpdateContent = fmt.Sprintf("I don't have a roll call tracked with the ID `%s` in this server.", id)
+			return
+		}
+
+		if err := reopenRollCall(session, rollCall); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed reopening roll call \"%s\": %v\n", id, err)
+			return
+		}
+
+		messageUpdateContent = fmt.Sprintf("Reopened the roll call `%s`.", id)
+	})
+}
+
+// closeRollCall removes the bot's own reactions from a roll call message and marks it closed.
+func closeRollCall(session *discordgo.Session, rollCall *config.RollCall) error {
+	guildConfig := config.GuildConfigOrDefault(rollCall.GuildID)
+	for emoji := range guildConfig.ReactionColours {
+		if err := session.MessageReactionRemove(rollCall.ChannelID, rollCall.MessageID, emoji, "@me"); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed removing %s reaction from roll call %s: %v\n", emoji, rollCall.MessageID, err)
+		}
+	}
+
+	rollCall.Closed = true
+	return config.GuildStore.PutRollCall(rollCall)
+}
+
+// reopenRollCall re-adds the bot's reactions to a closed roll call message, marks it open again,
+// and reschedules its expiry if it hasn't already passed.
+func reopenRollCall(session *discordgo.Session, rollCall *config.RollCall) error {
+	guildConfig := config.GuildConfigOrDefault(rollCall.GuildID)
+	for emoji := range guildConfig.ReactionColours {
+		if err := session.MessageReactionAdd(rollCall.ChannelID, rollCall.MessageID, emoji); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed adding %s reaction to roll call %s: %v\n", emoji, rollCall.MessageID, err)
+		}
+	}
+
+	rollCall.Closed = false
+	if err := config.GuildStore.PutRollCall(rollCall); err != nil {
+		return err
+	}
+
+	scheduleRollCallExpiry(session, rollCall)
+	return nil
+}
+
+// scheduleRollCallExpiry spawns a goroutine that closes the roll call once its ExpiresAt time is
+// reached. It's a no-op if the roll call has no expiry, is already closed, or has already expired.
+func scheduleRollCallExpiry(session *discordgo.Session, rollCall *config.RollCall) {
+	if rollCall.ExpiresAt == nil || rollCall.Closed {
+		return
+	}
+
+	wait := time.Until(*rollCall.ExpiresAt)
+	if wait <= 0 {
+		return
+	}
+
+	go func() {
+		time.Sleep(wait)
+
+		current, err := config.GuildStore.GetRollCall(rollCall.MessageID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed getting roll call %s to auto-close: %v\n", rollCall.MessageID, err)
+			return
+		}
+		if current == nil || current.Closed {
+			return
+		}
+
+		if err := closeRollCall(session, current); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed auto-closing expired roll call %s: %v\n", rollCall.MessageID, err)
+		}
+	}()
+}
+
+// resumeRollCalls re-subscribes to every persisted, unclosed roll call on startup: ones that
+// expired while the bot was down are closed immediately, and the rest have their expiry
+// (re)scheduled.
+func resumeRollCalls(session *discordgo.Session) {
+	rollCalls, err := config.GuildStore.ListRollCalls()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed listing roll calls to resume: %v\n", err)
+		return
+	}
+
+	resumed := 0
+	for _, rollCall := range rollCalls {
+		if rollCall.Closed {
+			continue
+		}
+
+		if rollCall.ExpiresAt != nil && !rollCall.ExpiresAt.After(time.Now()) {
+			if err := closeRollCall(session, rollCall); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed closing expired roll call %s: %v\n", rollCall.MessageID, err)
+			}
+			continue
+		}
+
+		scheduleRollCallExpiry(session, rollCall)
+		resumed++
+	}
+
+	fmt.Printf("Resumed tracking %d roll %s\n", resumed, textutil.Pluralise("call", "calls", resumed))
+}
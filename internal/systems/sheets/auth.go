@@ -0,0 +1,126 @@
+package sheets
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+)
+
+// impersonateFlag sets the subject to impersonate via domain-wide delegation. Only honoured by
+// the service_account and adc credential modes.
+var impersonateFlag = flag.String("impersonate", "", "Email address to impersonate via domain-wide delegation (service_account/adc credential modes only)")
+
+// credentialsSource produces an authenticated HTTP client for talking to Google APIs. Each
+// credential mode Rolly supports (interactive OAuth2, a service account key, or Application
+// Default Credentials) implements this the same way.
+type credentialsSource interface {
+	Client(ctx context.Context) (*http.Client, error)
+}
+
+// newCredentialsSource picks a credentialsSource based on cfg.Google.CredentialsMode.
+func newCredentialsSource(cfg *config.Config) (credentialsSource, error) {
+	switch cfg.Google.CredentialsMode {
+	case "", "oauth2":
+		return &oauth2CredentialsSource{
+			credentialsPath: cfg.Google.CredentialsPath,
+			tokenPath:       cfg.Google.TokenPath,
+		}, nil
+	case "service_account":
+		return &serviceAccountCredentialsSource{
+			keyPath:     cfg.Google.CredentialsPath,
+			impersonate: *impersonateFlag,
+		}, nil
+	case "adc":
+		return &adcCredentialsSource{
+			impersonate: *impersonateFlag,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown Google.CredentialsMode %q (expected \"oauth2\", \"service_account\", or \"adc\")", cfg.Google.CredentialsMode)
+	}
+}
+
+// oauth2CredentialsSource is the original 3-legged interactive OAuth2 flow, backed by a token
+// cached on disk.
+type oauth2CredentialsSource struct {
+	credentialsPath string
+	tokenPath       string
+}
+
+func (s *oauth2CredentialsSource) Client(ctx context.Context) (*http.Client, error) {
+	b, err := os.ReadFile(s.credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+	}
+
+	oauthConfig, err := google.ConfigFromJSON(b, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+	}
+
+	return getClient(s.tokenPath, oauthConfig), nil
+}
+
+// serviceAccountCredentialsSource authenticates using a service-account key file, for unattended
+// deployments (cron, CI, GKE) that shouldn't need a one-off interactive login.
+type serviceAccountCredentialsSource struct {
+	keyPath     string
+	impersonate string
+}
+
+func (s *serviceAccountCredentialsSource) Client(ctx context.Context) (*http.Client, error) {
+	b, err := os.ReadFile(s.keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account key file: %v", err)
+	}
+
+	creds, err := google.CredentialsFromJSON(ctx, b, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse service account key file: %v", err)
+	}
+
+	if s.impersonate != "" {
+		jwtConfig, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse service account key file as JWT config: %v", err)
+		}
+		jwtConfig.Subject = s.impersonate
+		return jwtConfig.Client(ctx), nil
+	}
+
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+// adcCredentialsSource authenticates via Application Default Credentials, honouring
+// GOOGLE_APPLICATION_CREDENTIALS or the GCE/GKE metadata server.
+type adcCredentialsSource struct {
+	impersonate string
+}
+
+func (s *adcCredentialsSource) Client(ctx context.Context) (*http.Client, error) {
+	creds, err := google.FindDefaultCredentials(ctx, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find default credentials: %v", err)
+	}
+
+	if s.impersonate != "" {
+		// Domain-wide delegation requires a JWT config derived from the underlying service
+		// account key, which is only available when ADC resolved to one (not when it resolved
+		// to GCE/GKE metadata credentials).
+		jwtConfig, err := google.JWTConfigFromJSON(creds.JSON, sheets.SpreadsheetsScope)
+		if err != nil {
+			return nil, fmt.Errorf("--impersonate requires ADC to resolve to a service account key: %v", err)
+		}
+		jwtConfig.Subject = s.impersonate
+		return jwtConfig.Client(ctx), nil
+	}
+
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
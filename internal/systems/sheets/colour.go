@@ -0,0 +1,153 @@
+package sheets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// Colour is a parsed RGBA colour value, normalised to the 0.0–1.0 floats the Sheets API expects
+// on CellFormat.BackgroundColor/TextFormat.ForegroundColor.
+type Colour struct {
+	R, G, B, A float64
+}
+
+// namedColours maps a handful of common CSS colour names to their hex value, so config authors
+// can write e.g. "red" or "cornflowerblue" instead of a hex code.
+var namedColours = map[string]string{
+	"black":          "000000",
+	"silver":         "c0c0c0",
+	"gray":           "808080",
+	"grey":           "808080",
+	"white":          "ffffff",
+	"maroon":         "800000",
+	"red":            "ff0000",
+	"purple":         "800080",
+	"fuchsia":        "ff00ff",
+	"magenta":        "ff00ff",
+	"green":          "008000",
+	"lime":           "00ff00",
+	"olive":          "808000",
+	"yellow":         "ffff00",
+	"navy":           "000080",
+	"blue":           "0000ff",
+	"teal":           "008080",
+	"aqua":           "00ffff",
+	"cyan":           "00ffff",
+	"orange":         "ffa500",
+	"pink":           "ffc0cb",
+	"brown":          "a52a2a",
+	"gold":           "ffd700",
+	"indigo":         "4b0082",
+	"violet":         "ee82ee",
+	"cornflowerblue": "6495ed",
+}
+
+// ParseColour parses a colour given as a CSS colour name (e.g. "cornflowerblue") or a hex string
+// in #RGB, #RRGGBB, or #RRGGBBAA form (the leading '#' is optional in all cases).
+func ParseColour(value string) (Colour, error) {
+	if hex, ok := namedColours[strings.ToLower(value)]; ok {
+		value = hex
+	}
+
+	switch len(strings.TrimPrefix(value, "#")) {
+	case 3:
+		return ParseShort(value)
+	case 6:
+		return ParseHex(value)
+	case 8:
+		return ParseHexAlpha(value)
+	default:
+		return Colour{}, fmt.Errorf("%q is not a recognised colour name or hex value", value)
+	}
+}
+
+// ParseHex parses a "#RRGGBB" (or bare "RRGGBB") hex colour string, with alpha defaulting to
+// fully opaque.
+func ParseHex(hex string) (Colour, error) {
+	hexStr := strings.TrimPrefix(hex, "#")
+	if len(hexStr) != 6 {
+		return Colour{}, fmt.Errorf("%q is not a 6-character #RRGGBB hex colour", hex)
+	}
+
+	values, err := strconv.ParseUint(hexStr, 16, 32)
+	if err != nil {
+		return Colour{}, fmt.Errorf("unable to parse hex colour: %v", err)
+	}
+
+	return Colour{
+		R: float64((values>>16)&0xFF) / 255,
+		G: float64((values>>8)&0xFF) / 255,
+		B: float64(values&0xFF) / 255,
+		A: 1,
+	}, nil
+}
+
+// ParseHexAlpha parses a "#RRGGBBAA" (or bare "RRGGBBAA") hex colour string.
+func ParseHexAlpha(hex string) (Colour, error) {
+	hexStr := strings.TrimPrefix(hex, "#")
+	if len(hexStr) != 8 {
+		return Colour{}, fmt.Errorf("%q is not an 8-character #RRGGBBAA hex colour", hex)
+	}
+
+	values, err := strconv.ParseUint(hexStr, 16, 32)
+	if err != nil {
+		return Colour{}, fmt.Errorf("unable to parse hex colour: %v", err)
+	}
+
+	return Colour{
+		R: float64((values>>24)&0xFF) / 255,
+		G: float64((values>>16)&0xFF) / 255,
+		B: float64((values>>8)&0xFF) / 255,
+		A: float64(values&0xFF) / 255,
+	}, nil
+}
+
+// ParseShort parses a shorthand "#RGB" (or bare "RGB") hex colour string, expanding each digit.
+func ParseShort(hex string) (Colour, error) {
+	hexStr := strings.TrimPrefix(hex, "#")
+	if len(hexStr) != 3 {
+		return Colour{}, fmt.Errorf("%q is not a 3-character #RGB hex colour", hex)
+	}
+
+	expanded := []byte{hexStr[0], hexStr[0], hexStr[1], hexStr[1], hexStr[2], hexStr[2]}
+	return ParseHex(string(expanded))
+}
+
+// ToSheetsColor converts the colour to the legacy *sheets.Color type used by
+// CellFormat.BackgroundColor and TextFormat.ForegroundColor. An omitted Alpha is interpreted by
+// the Sheets API as fully opaque (1.0), so a fully-transparent colour (Alpha 0) needs
+// ForceSendFields to actually be sent as zero instead of silently rendering as solid.
+func (c Colour) ToSheetsColor() *sheets.Color {
+	color := &sheets.Color{
+		Red:   c.R,
+		Green: c.G,
+		Blue:  c.B,
+		Alpha: c.A,
+	}
+	if c.A == 0 {
+		color.ForceSendFields = append(color.ForceSendFields, "Alpha")
+	}
+	return color
+}
+
+// ToSheetsColorStyle converts the colour to the newer *sheets.ColorStyle type, which supersedes
+// Color on recent CellFormat/TextFormat fields.
+func (c Colour) ToSheetsColorStyle() *sheets.ColorStyle {
+	return &sheets.ColorStyle{
+		RgbColor: c.ToSheetsColor(),
+	}
+}
+
+// hexToRGB converts the given hexadecimal colour value string to its component red, green, and
+// blue values in the 0–255 range. Kept as a thin wrapper around ParseHex for callers that haven't
+// moved to the Colour type yet.
+func hexToRGB(hex string) (int, int, int, error) {
+	c, err := ParseHex(hex)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return int(c.R * 255), int(c.G * 255), int(c.B * 255), nil
+}
@@ -0,0 +1,70 @@
+package sheets
+
+import "testing"
+
+func TestParseColour(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Colour
+		wantErr bool
+	}{
+		{name: "hex with hash", input: "#ff0000", want: Colour{R: 1, G: 0, B: 0, A: 1}},
+		{name: "bare hex", input: "00ff00", want: Colour{R: 0, G: 1, B: 0, A: 1}},
+		{name: "hex with alpha", input: "#0000ff80", want: Colour{R: 0, G: 0, B: 1, A: float64(0x80) / 255}},
+		{name: "shorthand", input: "#f00", want: Colour{R: 1, G: 0, B: 0, A: 1}},
+		{name: "named colour", input: "cornflowerblue", want: Colour{R: float64(0x64) / 255, G: float64(0x95) / 255, B: float64(0xed) / 255, A: 1}},
+		{name: "named colour case insensitive", input: "RED", want: Colour{R: 1, G: 0, B: 0, A: 1}},
+		{name: "invalid", input: "not-a-colour", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseColour(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseColour(%q) = %+v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseColour(%q) returned unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseColour(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToSheetsColorTransparent(t *testing.T) {
+	c, err := ParseColour("#00000000")
+	if err != nil {
+		t.Fatalf("ParseColour returned unexpected error: %v", err)
+	}
+
+	color := c.ToSheetsColor()
+	if color.Alpha != 0 {
+		t.Fatalf("ToSheetsColor().Alpha = %v, want 0", color.Alpha)
+	}
+
+	found := false
+	for _, field := range color.ForceSendFields {
+		if field == "Alpha" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("ToSheetsColor().ForceSendFields = %v, want it to include \"Alpha\" so a zero alpha isn't dropped by omitempty", color.ForceSendFields)
+	}
+}
+
+func TestHexToRGBBackwardsCompat(t *testing.T) {
+	r, g, b, err := hexToRGB("ff8000")
+	if err != nil {
+		t.Fatalf("hexToRGB returned unexpected error: %v", err)
+	}
+	if r != 0xff || g != 0x80 || b != 0x00 {
+		t.Fatalf("hexToRGB(\"ff8000\") = (%d, %d, %d), want (255, 128, 0)", r, g, b)
+	}
+}
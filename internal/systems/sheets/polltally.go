@@ -0,0 +1,120 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+	"rolly/internal/msgtemplate"
+)
+
+// ProcessPollTallyQueue drains every pending poll tally update currently on queue, coalesces
+// repeated updates to the same range down to the latest one, and writes each guild's tallies to
+// its spreadsheet using store to resolve the guild's SheetID. It's called from the same ticker as
+// ProcessQueue.
+func ProcessPollTallyQueue(queue <-chan config.PollTallyUpdate, store config.Store) {
+	updates := make([]config.PollTallyUpdate, 0)
+	timeout := time.NewTimer(10_000_000)
+	queueFlushed := false
+	for !queueFlushed {
+		select {
+		case item := <-queue:
+			updates = append(updates, item)
+		case <-timeout.C:
+			queueFlushed = true
+		}
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+
+	// Coalesce repeated updates to the same guild+range down to the latest one, same as
+	// processQueue does per-name for colour updates.
+	type key struct {
+		guildID string
+		rangeA1 string
+	}
+	latest := make(map[key]config.PollTallyUpdate)
+	for _, update := range updates {
+		latest[key{guildID: update.GuildID, rangeA1: update.RangeA1}] = update
+	}
+
+	for _, update := range latest {
+		guildConfig, err := store.Get(update.GuildID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get guild config for \"%s\": %v\n", update.GuildID, err)
+			continue
+		}
+		if guildConfig.SheetID == "" {
+			continue
+		}
+
+		if config.IsDryRun() {
+			fmt.Printf("[dry run] would write poll tally %v to \"%s\" in spreadsheet \"%s\"\n", update.Counts, update.RangeA1, guildConfig.SheetID)
+			continue
+		}
+
+		values := make([]interface{}, len(update.Counts))
+		for i, count := range update.Counts {
+			values[i] = count
+		}
+
+		if err := valuesUpdateWithRetry(currentService(), guildConfig.SheetID, update.RangeA1, values); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write poll tally to \"%s\": %v\n", update.RangeA1, err)
+			continue
+		}
+
+		total := 0
+		for _, count := range update.Counts {
+			total += count
+		}
+		fmt.Println(msgtemplate.Render(config.CurrentTemplates().OnRangeUpdated, msgtemplate.Context{
+			Values: map[string]string{"range": update.RangeA1, "count": fmt.Sprint(total)},
+			Count:  total,
+		}))
+	}
+}
+
+// valuesUpdateWithRetry waits for the shared rate limiter, writes values as a single row starting
+// at rangeA1's top-left cell, and retries with exponential backoff on 429/5xx responses, same as
+// batchUpdateWithRetry.
+func valuesUpdateWithRetry(sheetsService *sheets.Service, sheetID string, rangeA1 string, values []interface{}) error {
+	ctx := context.Background()
+
+	var lastErr error
+	for attempt := 0; attempt <= maxBatchUpdateRetries; attempt++ {
+		if err := sheetsLimiter.Wait(ctx); err != nil {
+			return fmt.Errorf("failed waiting for rate limiter: %v", err)
+		}
+
+		_, err := sheetsService.Spreadsheets.Values.Update(sheetID, rangeA1, &sheets.ValueRange{
+			Values: [][]interface{}{values},
+		}).ValueInputOption("RAW").Do()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		var apiErr *googleapi.Error
+		retryable := errors.As(err, &apiErr) && (apiErr.Code == 429 || apiErr.Code >= 500)
+		if !retryable || attempt == maxBatchUpdateRetries {
+			break
+		}
+
+		wait := retryAfter(apiErr)
+		if wait <= 0 {
+			wait = backoff(attempt)
+		}
+		fmt.Fprintf(os.Stderr, "poll tally update failed (%v), retrying in %v\n", err, wait)
+		time.Sleep(wait)
+	}
+
+	return lastErr
+}
@@ -0,0 +1,263 @@
+package sheets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+	"rolly/internal/sheetutil"
+	"rolly/internal/systems/commands"
+)
+
+// registerPreviewCommands registers the slash commands for inspecting range matches and toggling
+// dry-run mode without risking a write to the configured sheet.
+func registerPreviewCommands(cfg *config.Config) {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "preview",
+		Description: "Shows what a name would match against the configured sheet ranges, without writing anything",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "name",
+				Description: "Name to look for a match for",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "preview") {
+			return
+		}
+
+		messageContent := "Looking for a match..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		name := i.ApplicationCommandData().Options[0].StringValue()
+		guildConfig := config.GuildConfigOrDefault(i.GuildID)
+
+		if guildConfig.SheetID == "" {
+			messageUpdateContent := "I don't have a sheet ID set, so I can't preview anything. You can give me one with `/setsheet`."
+			commands.Update(session, i.Interaction, &messageUpdateContent)
+			return
+		}
+
+		embed, err := buildPreviewEmbed(currentService(), guildConfig, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed building preview for \"%s\": %v\n", name, err)
+			messageUpdateContent := "I couldn't build a preview for that name."
+			commands.Update(session, i.Interaction, &messageUpdateContent)
+			return
+		}
+
+		emptyContent := ""
+		if _, err := session.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &emptyContent,
+			Embeds:  &[]*discordgo.MessageEmbed{embed},
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed updating interaction response: %v\n", err)
+		}
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "dryrun",
+		Description: "Enables or disables dry-run mode, where sheet updates are logged instead of written",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "state",
+				Description: "Whether dry-run mode should be on or off",
+				Required:    true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "on", Value: "on"},
+					{Name: "off", Value: "off"},
+				},
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "dryrun") {
+			return
+		}
+
+		if !config.IsBotOwner(i.Member.User.ID) {
+			messageContent := "Sorry, only a bot owner can change dry-run mode."
+			commands.Respond(session, i.Interaction, &messageContent)
+			return
+		}
+
+		state := i.ApplicationCommandData().Options[0].StringValue()
+
+		config.Mutex.Lock()
+		config.Current.DryRun = state == "on"
+		saveErr := config.SaveConfig(config.Current, configPath)
+		config.Mutex.Unlock()
+		if saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving config after setting dry-run: %v\n", saveErr)
+		}
+
+		messageContent := fmt.Sprintf("Dry-run mode is now %s.", state)
+		commands.Respond(session, i.Interaction, &messageContent)
+	})
+}
+
+// previewMatch is a single name match found while previewing the configured sheet ranges.
+type previewMatch struct {
+	CellValue     string
+	RangeSheet    string
+	X, Y          int
+	RowContext    []string
+	ColumnContext []string
+}
+
+// findPreviewMatch looks for the first cell value across ranges that name contains, mirroring the
+// matching semantics of sheetNameIndex.find, and returns its position along with the surrounding
+// row and column values for context.
+func findPreviewMatch(sheetsService *sheets.Service, sheetID string, ranges []string, name string) (*previewMatch, error) {
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+
+	result, err := sheetsService.Spreadsheets.Values.BatchGet(sheetID).Ranges(ranges...).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ranges from spreadsheet: %v", err)
+	}
+
+	for rangeIndex, valueRange := range result.ValueRanges {
+		parsed, err := sheetutil.ParseA1Notation(ranges[rangeIndex])
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing A1 range \"%s\": %v", ranges[rangeIndex], err)
+		}
+
+		for majorIndex, majorDimension := range valueRange.Values {
+			for minorIndex, cell := range majorDimension {
+				cellValue, isString := cell.(string)
+				if !isString || cellValue == "" || !strings.Contains(name, cellValue) {
+					continue
+				}
+
+				match := &previewMatch{CellValue: cellValue, RangeSheet: parsed.Sheet}
+				if valueRange.MajorDimension == "COLUMNS" {
+					match.X = parsed.X + majorIndex
+					match.Y = parsed.Y + minorIndex
+					match.ColumnContext = stringsFromSlice(majorDimension)
+					match.RowContext = crossSection(valueRange.Values, minorIndex)
+				} else {
+					match.X = parsed.X + minorIndex
+					match.Y = parsed.Y + majorIndex
+					match.RowContext = stringsFromSlice(majorDimension)
+					match.ColumnContext = crossSection(valueRange.Values, minorIndex)
+				}
+
+				return match, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// stringsFromSlice stringifies each value in a single row or column of BatchGet results.
+func stringsFromSlice(values []interface{}) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%v", v)
+	}
+	return out
+}
+
+// crossSection pulls the value at index out of each row (or column) in values, giving the context
+// perpendicular to whichever majorDimension the BatchGet result already groups by.
+func crossSection(values [][]interface{}, index int) []string {
+	out := make([]string, 0, len(values))
+	for _, row := range values {
+		if index < len(row) {
+			out = append(out, fmt.Sprintf("%v", row[index]))
+		} else {
+			out = append(out, "")
+		}
+	}
+	return out
+}
+
+// fetchCellFormat returns the sheet title and current background colour (if any) of the single
+// cell at a1, which may be sheet-qualified (e.g. `'My Sheet'!B3`).
+func fetchCellFormat(sheetsService *sheets.Service, sheetID string, a1 string) (string, *Colour, error) {
+	resp, err := sheetsService.Spreadsheets.Get(sheetID).
+		Ranges(a1).
+		Fields("sheets.properties.title,sheets.data.rowData.values.userEnteredFormat.backgroundColor").
+		Do()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get cell formatting: %v", err)
+	}
+	if len(resp.Sheets) == 0 {
+		return "", nil, errors.New("spreadsheet returned no matching sheet")
+	}
+
+	sheet := resp.Sheets[0]
+	title := sheet.Properties.Title
+
+	var colour *Colour
+	if len(sheet.Data) > 0 && len(sheet.Data[0].RowData) > 0 && len(sheet.Data[0].RowData[0].Values) > 0 {
+		format := sheet.Data[0].RowData[0].Values[0].UserEnteredFormat
+		if format != nil && format.BackgroundColor != nil {
+			c := format.BackgroundColor
+			alpha := c.Alpha
+			if alpha == 0 {
+				// The API omits Alpha entirely for a fully opaque colour, which unmarshals as 0.
+				alpha = 1
+			}
+			colour = &Colour{R: c.Red, G: c.Green, B: c.Blue, A: alpha}
+		}
+	}
+
+	return title, colour, nil
+}
+
+// buildPreviewEmbed builds the Discord embed shown by /preview: the matched cell's A1 coordinates
+// and sheet title, its current background colour as a swatch, and the row/column values it was
+// matched among.
+func buildPreviewEmbed(sheetsService *sheets.Service, guildConfig *config.GuildConfig, name string) (*discordgo.MessageEmbed, error) {
+	match, err := findPreviewMatch(sheetsService, guildConfig.SheetID, guildConfig.SheetRanges(), name)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("No match for \"%s\"", name),
+			Description: "None of the configured ranges contain a cell value that name matches against.",
+		}, nil
+	}
+
+	cellA1 := fmt.Sprintf("%s%d", sheetutil.ToA1Column(match.X), match.Y+1)
+	qualifiedA1 := cellA1
+	if match.RangeSheet != "" {
+		qualifiedA1 = fmt.Sprintf("'%s'!%s", match.RangeSheet, cellA1)
+	}
+
+	sheetTitle, colour, err := fetchCellFormat(sheetsService, guildConfig.SheetID, qualifiedA1)
+	if err != nil {
+		return nil, err
+	}
+
+	colourText := "not set"
+	embedColour := 0
+	if colour != nil {
+		r, g, b := int(colour.R*255), int(colour.G*255), int(colour.B*255)
+		colourText = fmt.Sprintf("#%02X%02X%02X", r, g, b)
+		embedColour = (r << 16) | (g << 8) | b
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Preview for \"%s\"", name),
+		Color: embedColour,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Matched cell", Value: fmt.Sprintf("`%s` on \"%s\" (matched `%s`)", cellA1, sheetTitle, match.CellValue)},
+			{Name: "Current colour", Value: colourText},
+			{Name: "Row context", Value: fmt.Sprintf("`%s`", strings.Join(match.RowContext, "`, `"))},
+			{Name: "Column context", Value: fmt.Sprintf("`%s`", strings.Join(match.ColumnContext, "`, `"))},
+		},
+	}, nil
+}
@@ -0,0 +1,402 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+	"rolly/internal/sheetutil"
+)
+
+// nameIndexTTL bounds how long a cached name→cell index is trusted before being rebuilt, so
+// spreadsheet edits (rows added/removed, names changed) eventually get picked up without a
+// restart.
+const nameIndexTTL = 60 * time.Second
+
+// nameIndexEntry is the location of a single matched name within a sheet, including the numeric ID
+// of the sheet tab it's on (resolved from the A1 range's sheet name, if any), since that's what
+// GridRange needs to target the right tab in a multi-tab spreadsheet.
+type nameIndexEntry struct {
+	X, Y    int
+	SheetID int64
+}
+
+// sheetNameIndex is a cached name→cell lookup for a single guild's sheet, built from one BatchGet
+// covering every configured range rather than one BatchGet per name.
+type sheetNameIndex struct {
+	sheetID string
+	ranges  []string
+	builtAt time.Time
+	entries map[string]nameIndexEntry
+}
+
+// stale reports whether the index needs rebuilding: it's never been built, its TTL has elapsed, the
+// guild's sheet ID has changed (e.g. via /setsheet), or the configured ranges have changed since it
+// was built.
+func (idx *sheetNameIndex) stale(sheetID string, ranges []string) bool {
+	if idx == nil || time.Since(idx.builtAt) > nameIndexTTL {
+		return true
+	}
+	if idx.sheetID != sheetID {
+		return true
+	}
+	if len(idx.ranges) != len(ranges) {
+		return true
+	}
+	for i, r := range ranges {
+		if idx.ranges[i] != r {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the cell location of an indexed cell value that name contains, preserving the
+// original substring-match semantics (a sheet cell might hold just a first name, while the
+// Discord nickname includes a surname, tag, etc.). The index is a map, so when several cell values
+// match, which one is returned is not defined.
+func (idx *sheetNameIndex) find(name string) (nameIndexEntry, bool) {
+	for cellValue, entry := range idx.entries {
+		if cellValue != "" && strings.Contains(name, cellValue) {
+			return entry, true
+		}
+	}
+	return nameIndexEntry{}, false
+}
+
+var (
+	nameIndexMutex sync.Mutex
+	nameIndexes    = make(map[string]*sheetNameIndex) // keyed by guild ID
+)
+
+// getNameIndex returns the cached name index for the given guild's sheet, rebuilding it with a
+// single BatchGet if it's missing, stale, or the configured ranges have changed.
+func getNameIndex(sheetsService *sheets.Service, guildID string, sheetID string, ranges []string) (*sheetNameIndex, error) {
+	nameIndexMutex.Lock()
+	idx := nameIndexes[guildID]
+	nameIndexMutex.Unlock()
+
+	if !idx.stale(sheetID, ranges) {
+		return idx, nil
+	}
+
+	entries, err := buildNameIndex(sheetsService, guildID, sheetID, ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	idx = &sheetNameIndex{sheetID: sheetID, ranges: ranges, builtAt: time.Now(), entries: entries}
+	nameIndexMutex.Lock()
+	nameIndexes[guildID] = idx
+	nameIndexMutex.Unlock()
+
+	return idx, nil
+}
+
+// buildNameIndex performs a single BatchGet covering every given range and returns a map of
+// matched cell value to its absolute (x, y) position and resolved sheet tab ID.
+func buildNameIndex(sheetsService *sheets.Service, guildID string, sheetID string, ranges []string) (map[string]nameIndexEntry, error) {
+	if len(ranges) == 0 {
+		return map[string]nameIndexEntry{}, nil
+	}
+
+	result, err := sheetsService.Spreadsheets.Values.BatchGet(sheetID).Ranges(ranges...).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ranges from spreadsheet: %v", err)
+	}
+
+	entries := make(map[string]nameIndexEntry)
+	for _, valueRange := range result.ValueRanges {
+		// Get the x and y offset of this range
+		parsed, err := sheetutil.ParseA1Notation(valueRange.Range)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing A1 range from result: %v", err)
+		}
+
+		tabID, err := getSheetTabID(sheetsService, guildID, sheetID, parsed.Sheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed resolving sheet tab for range \"%s\": %v", valueRange.Range, err)
+		}
+
+		for majorIndex, majorDimension := range valueRange.Values {
+			for minorIndex, cell := range majorDimension {
+				cellValue, isString := cell.(string)
+				if !isString || cellValue == "" {
+					continue
+				}
+
+				// Figure out whether we were iterating horizontally or vertically to apply the
+				// appropriate offsets
+				if valueRange.MajorDimension == "COLUMNS" {
+					entries[cellValue] = nameIndexEntry{X: parsed.X + majorIndex, Y: parsed.Y + minorIndex, SheetID: tabID}
+				} else /*if valueRange.MajorDimension == "ROWS"*/ { // Always default to rows as this is standard
+					entries[cellValue] = nameIndexEntry{X: parsed.X + minorIndex, Y: parsed.Y + majorIndex, SheetID: tabID}
+				}
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// sheetTabCache is a cached sheet-tab-name→ID lookup for a single guild's spreadsheet, built from
+// one Spreadsheets.Get call rather than one per write.
+type sheetTabCache struct {
+	sheetID   string
+	builtAt   time.Time
+	idsByName map[string]int64
+}
+
+func (c *sheetTabCache) stale(sheetID string) bool {
+	return c == nil || c.sheetID != sheetID || time.Since(c.builtAt) > nameIndexTTL
+}
+
+var (
+	sheetTabMutex sync.Mutex
+	sheetTabs     = make(map[string]*sheetTabCache) // keyed by guild ID
+)
+
+// getSheetTabID resolves sheetName (the sheet-qualified part of an A1 range, e.g. `Other Sheet` in
+// `'Other Sheet'!A1:B2`) to its numeric sheet ID, as required by GridRange. An empty sheetName (an
+// unqualified range) resolves to 0, the default first tab, without a metadata fetch.
+func getSheetTabID(sheetsService *sheets.Service, guildID string, sheetID string, sheetName string) (int64, error) {
+	if sheetName == "" {
+		return 0, nil
+	}
+
+	sheetTabMutex.Lock()
+	cache := sheetTabs[guildID]
+	sheetTabMutex.Unlock()
+
+	if cache.stale(sheetID) {
+		spreadsheet, err := sheetsService.Spreadsheets.Get(sheetID).Fields("sheets.properties").Do()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get spreadsheet metadata: %v", err)
+		}
+
+		idsByName := make(map[string]int64, len(spreadsheet.Sheets))
+		for _, sheet := range spreadsheet.Sheets {
+			idsByName[sheet.Properties.Title] = sheet.Properties.SheetId
+		}
+
+		cache = &sheetTabCache{sheetID: sheetID, builtAt: time.Now(), idsByName: idsByName}
+		sheetTabMutex.Lock()
+		sheetTabs[guildID] = cache
+		sheetTabMutex.Unlock()
+	}
+
+	id, found := cache.idsByName[sheetName]
+	if !found {
+		return 0, fmt.Errorf("sheet tab \"%s\" not found in spreadsheet", sheetName)
+	}
+	return id, nil
+}
+
+// processQueue will consume all available items in the given queue, group them by guild, coalesce
+// duplicate updates for the same name down to their highest-priority colour, and push each
+// guild's changes to its own sheet using the given sheetsService instance and store.
+func processQueue(queue <-chan config.NameColourUpdate, sheetsService *sheets.Service, store config.Store) {
+	// Read in the next however many values are in the queue and stop if it takes more than 10ms to do so
+	nameColourQueue := make([]config.NameColourUpdate, 0)
+	timeout := time.NewTimer(10_000_000)
+	queueFlushed := false
+	for !queueFlushed {
+		select {
+		case item := <-queue: // Still items remaining in the queue
+			nameColourQueue = append(nameColourQueue, item)
+		case <-timeout.C: // Either no more items remaining or took too long copying
+			queueFlushed = true
+		}
+	}
+
+	if len(nameColourQueue) == 0 {
+		// Nothing to do
+		return
+	}
+
+	// Group updates by guild, since each guild has its own sheet and ranges to update
+	byGuild := make(map[string][]config.NameColourUpdate)
+	for _, item := range nameColourQueue {
+		byGuild[item.GuildID] = append(byGuild[item.GuildID], item)
+	}
+
+	for guildID, items := range byGuild {
+		guildConfig, err := store.Get(guildID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get guild config for \"%s\": %v\n", guildID, err)
+			continue
+		}
+		if guildConfig.SheetID == "" {
+			// No sheet configured for this guild, nothing to update
+			continue
+		}
+
+		processGuildQueue(coalesceUpdates(items), sheetsService, guildID, guildConfig.SheetID, guildConfig.SheetRanges())
+	}
+}
+
+// coalesceUpdates collapses multiple updates for the same name down to the one with the highest
+// priority (lowest Priority value), so a burst of reactions on one roll call only produces one
+// write per user.
+func coalesceUpdates(items []config.NameColourUpdate) map[string]config.ColourPriority {
+	byName := make(map[string]config.ColourPriority)
+	for _, item := range items {
+		existing, exists := byName[item.Name]
+		if !exists || item.Priority < existing.Priority {
+			byName[item.Name] = config.ColourPriority{Colour: item.Colour, Priority: item.Priority}
+		}
+	}
+	return byName
+}
+
+// processGuildQueue pushes a single guild's coalesced name colour updates to its spreadsheet,
+// resolving cell locations from a cached name index rather than a BatchGet per name.
+func processGuildQueue(updates map[string]config.ColourPriority, sheetsService *sheets.Service, guildID string, sheetID string, sheetRanges []string) {
+	if len(updates) == 0 {
+		return
+	}
+
+	index, err := getNameIndex(sheetsService, guildID, sheetID, sheetRanges)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build name index: %v\n", err)
+		return
+	}
+
+	cellUpdateQueue := make([]*sheets.Request, 0)
+	queuedNames := make([]string, 0, len(updates))
+	for name, update := range updates {
+		entry, found := index.find(name)
+		if !found {
+			// Couldn't find the name, skip it
+			continue
+		}
+
+		colour, err := ParseColour(update.Colour)
+		if err != nil {
+			// Failed to convert colour, complain and continue
+			fmt.Fprintf(os.Stderr, "failed to parse colour: %v\n", err)
+			continue
+		}
+
+		cellUpdateQueue = append(cellUpdateQueue, &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Cell: &sheets.CellData{
+					UserEnteredFormat: &sheets.CellFormat{
+						BackgroundColor: colour.ToSheetsColor(),
+					},
+				},
+				Fields: "UserEnteredFormat(BackgroundColor)",
+				Range: &sheets.GridRange{
+					StartColumnIndex: int64(entry.X),
+					StartRowIndex:    int64(entry.Y),
+					EndColumnIndex:   int64(entry.X + 1),
+					EndRowIndex:      int64(entry.Y + 1),
+					SheetId:          entry.SheetID,
+				},
+			},
+		})
+		queuedNames = append(queuedNames, name)
+	}
+
+	if len(cellUpdateQueue) == 0 {
+		return
+	}
+
+	if config.IsDryRun() {
+		fmt.Printf("[dry run] would submit %d cell update(s) to spreadsheet \"%s\":\n", len(cellUpdateQueue), sheetID)
+		for _, name := range queuedNames {
+			fmt.Printf("[dry run]   %s -> %s\n", name, updates[name].Colour)
+		}
+		return
+	}
+
+	if err := batchUpdateWithRetry(sheetsService, sheetID, cellUpdateQueue); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to submit batch update: %v\n", err)
+	}
+}
+
+// sheetsLimiter throttles BatchUpdate calls to stay under the documented 60 writes/minute/user
+// Sheets API quota. Shared across every guild, since they all go out under the same credentials.
+var sheetsLimiter = rate.NewLimiter(rate.Every(time.Minute/60), 1)
+
+// maxBatchUpdateRetries bounds how many times batchUpdateWithRetry will retry a failed
+// BatchUpdate before giving up.
+const maxBatchUpdateRetries = 5
+
+// batchUpdateWithRetry waits for the rate limiter, submits the batch update, and retries with
+// exponential backoff on 429 (rate limited) or 5xx (transient server error) responses, honouring
+// the Retry-After header when the API sends one.
+func batchUpdateWithRetry(sheetsService *sheets.Service, sheetID string, requests []*sheets.Request) error {
+	ctx := context.Background()
+
+	var lastErr error
+	for attempt := 0; attempt <= maxBatchUpdateRetries; attempt++ {
+		if err := sheetsLimiter.Wait(ctx); err != nil {
+			return fmt.Errorf("failed waiting for rate limiter: %v", err)
+		}
+
+		_, err := sheetsService.Spreadsheets.BatchUpdate(sheetID, &sheets.BatchUpdateSpreadsheetRequest{
+			IncludeSpreadsheetInResponse: false,
+			Requests:                     requests,
+		}).Do()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		var apiErr *googleapi.Error
+		retryable := errors.As(err, &apiErr) && (apiErr.Code == 429 || apiErr.Code >= 500)
+		if !retryable || attempt == maxBatchUpdateRetries {
+			break
+		}
+
+		wait := retryAfter(apiErr)
+		if wait <= 0 {
+			wait = backoff(attempt)
+		}
+		fmt.Fprintf(os.Stderr, "batch update failed (%v), retrying in %v\n", err, wait)
+		time.Sleep(wait)
+	}
+
+	return lastErr
+}
+
+// retryAfter returns the duration indicated by the API error's Retry-After header, or zero if
+// it's absent or unparseable.
+func retryAfter(apiErr *googleapi.Error) time.Duration {
+	if apiErr == nil || apiErr.Header == nil {
+		return 0
+	}
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+	return 0
+}
+
+// backoff returns an exponential backoff duration for the given attempt number, with jitter so
+// retries from different guilds don't all land in the same instant.
+func backoff(attempt int) time.Duration {
+	base := time.Second * time.Duration(math.Pow(2, float64(attempt)))
+	jitter := time.Duration(rand.Int63n(int64(base)/2 + 1))
+	return base + jitter
+}
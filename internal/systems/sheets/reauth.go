@@ -0,0 +1,183 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+)
+
+// oauthCallbackServer is a long-lived HTTP server handling Google's OAuth2 redirect, so
+// reauthorization can happen through a DM'd link instead of requiring SSH access to the host.
+// Unlike the loopback server getTokenFromWebLoopback spins up for the one-off interactive setup
+// flow, this one stays up for the lifetime of the process and can service any number of
+// in-flight authorization attempts, each tracked by its own random state token.
+type oauthCallbackServer struct {
+	server *http.Server
+
+	mutex   sync.Mutex
+	pending map[string]chan oauthCallbackResult
+}
+
+type oauthCallbackResult struct {
+	code string
+	err  error
+}
+
+// oauthServer handles Google's OAuth2 redirect for the /reauth command. It's only started when
+// Google.CredentialsMode is "oauth2" ("" counts as "oauth2"); left nil otherwise so /reauth can
+// report that reauthorization isn't applicable.
+var oauthServer *oauthCallbackServer
+
+// startOAuthCallbackServer starts an HTTP server on addr that handles Google's OAuth2 redirect.
+// addr defaults to the host:port parsed out of redirectURL if empty.
+func startOAuthCallbackServer(addr string, redirectURL string) (*oauthCallbackServer, error) {
+	if addr == "" {
+		parsed, err := url.Parse(redirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse Google redirect URL: %v", err)
+		}
+		addr = parsed.Host
+		if addr == "" {
+			return nil, errors.New("Google redirect URL has no host to derive a listen address from")
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to bind OAuth2 callback listener on \"%s\": %v", addr, err)
+	}
+
+	s := &oauthCallbackServer{
+		pending: make(map[string]chan oauthCallbackResult),
+	}
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handleCallback)}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "OAuth2 callback server stopped unexpectedly: %v\n", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// handleCallback validates the state query parameter against a pending authorization attempt and
+// hands the result back to whichever goroutine is waiting on it.
+func (s *oauthCallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	state := query.Get("state")
+
+	s.mutex.Lock()
+	resultCh, exists := s.pending[state]
+	if exists {
+		delete(s.pending, state)
+	}
+	s.mutex.Unlock()
+
+	if !exists {
+		http.Error(w, "Unknown or expired authorization attempt, please try again", http.StatusBadRequest)
+		return
+	}
+
+	if errMsg := query.Get("error"); errMsg != "" {
+		http.Error(w, fmt.Sprintf("Authorization failed: %s", errMsg), http.StatusBadRequest)
+		resultCh <- oauthCallbackResult{err: fmt.Errorf("authorization denied: %s", errMsg)}
+		return
+	}
+
+	fmt.Fprint(w, "Authorization complete, you can close this tab and return to Rolly.")
+	resultCh <- oauthCallbackResult{code: query.Get("code")}
+}
+
+// beginAuth registers a new pending authorization attempt under a random state token and returns
+// the channel its result will be delivered on.
+func (s *oauthCallbackServer) beginAuth() (state string, resultCh chan oauthCallbackResult, err error) {
+	state, err = randomState()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to generate OAuth2 state: %v", err)
+	}
+
+	resultCh = make(chan oauthCallbackResult, 1)
+	s.mutex.Lock()
+	s.pending[state] = resultCh
+	s.mutex.Unlock()
+
+	return state, resultCh, nil
+}
+
+// RequestReauth builds a fresh authorization URL for the given OAuth2 config, DMs it to userID,
+// then waits in the background for the resulting redirect, exchanges the code, saves the token to
+// cfg.Google.TokenPath, rebuilds the live Sheets client from it, and DMs the outcome back to the
+// user. It returns once the link has been sent, not once authorization completes.
+func (s *oauthCallbackServer) RequestReauth(session *discordgo.Session, userID string, oauthConfig *oauth2.Config, cfg *config.Config) error {
+	state, resultCh, err := s.beginAuth()
+	if err != nil {
+		return err
+	}
+
+	authURL := oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+
+	channel, err := session.UserChannelCreate(userID)
+	if err != nil {
+		return fmt.Errorf("unable to open a DM channel: %v", err)
+	}
+
+	_, err = session.ChannelMessageSend(channel.ID, fmt.Sprintf("Here's a fresh authorization link: %s", authURL))
+	if err != nil {
+		return fmt.Errorf("unable to send authorization link: %v", err)
+	}
+
+	go func() {
+		res := <-resultCh
+
+		var followUp string
+		if res.err != nil {
+			followUp = fmt.Sprintf("Reauthorization failed: %v", res.err)
+		} else if tok, err := oauthConfig.Exchange(context.Background(), res.code); err != nil {
+			followUp = fmt.Sprintf("Reauthorization failed: unable to retrieve token: %v", err)
+		} else {
+			saveToken(cfg.Google.TokenPath, tok)
+
+			if srv, err := newSheetsService(cfg); err != nil {
+				followUp = fmt.Sprintf("Token saved, but I couldn't rebuild the Sheets client: %v. A restart is needed to pick up the new token.", err)
+			} else {
+				setService(srv)
+				followUp = "Reauthorization complete!"
+			}
+		}
+
+		if _, err := session.ChannelMessageSend(channel.ID, followUp); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed sending reauthorization result DM: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// reauthOAuthConfig loads the OAuth2 client config used for reauthorization from
+// cfg.Google.CredentialsPath.
+func reauthOAuthConfig(cfg *config.Config) (*oauth2.Config, error) {
+	b, err := os.ReadFile(cfg.Google.CredentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %v", err)
+	}
+
+	oauthConfig, err := google.ConfigFromJSON(b, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+	}
+
+	return oauthConfig, nil
+}
@@ -0,0 +1,344 @@
+// Package sheets owns everything related to talking to Google Sheets: authentication, the
+// update queue that coalesces and pushes name colour changes, and the /sheet, /setsheet,
+// /ranges, /addrange, /setranges, /preview, /dryrun, and /reauth commands.
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	set "github.com/deckarep/golang-set/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+
+	"rolly/internal/config"
+	"rolly/internal/sheetutil"
+	"rolly/internal/systems/commands"
+	"rolly/internal/textutil"
+)
+
+// Service is the authenticated Sheets API client, first set during Init and rebuilt by /reauth
+// once a fresh token has been obtained. serviceMutex guards every read and write of it (through
+// currentService and setService), since Init
+// runs on the main goroutine while /reauth rebuilds it from the background goroutine that waits on
+// the OAuth2 callback.
+var (
+	Service      *sheets.Service
+	serviceMutex sync.RWMutex
+)
+
+// currentService returns the Sheets API client currently in use.
+func currentService() *sheets.Service {
+	serviceMutex.RLock()
+	defer serviceMutex.RUnlock()
+	return Service
+}
+
+// setService replaces the Sheets API client in use, e.g. after /reauth obtains a fresh token.
+func setService(srv *sheets.Service) {
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+	Service = srv
+}
+
+// configPath is where config was loaded from, kept around so commands that mutate config (e.g.
+// /dryrun) can persist the change immediately rather than waiting for a SIGHUP or clean exit.
+var configPath string
+
+// a1RangeTokenPattern splits a space-separated list of A1 ranges into individual tokens, taking
+// care not to split on spaces inside a quoted sheet name (e.g. `'My Sheet'!A1:B2`).
+var a1RangeTokenPattern = regexp.MustCompile(`'[^']*'![^\s]+|[^\s]+`)
+
+// Init authenticates with Google, registers this subsystem's commands, and starts the OAuth2
+// callback server used by /reauth (if Google.CredentialsMode calls for the interactive flow).
+func Init(session *discordgo.Session, cfg *config.Config, _ chan<- config.NameColourUpdate) error {
+	srv, err := newSheetsService(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialise sheets service: %v", err)
+	}
+	setService(srv)
+
+	if cfg.Google.CredentialsMode == "" || cfg.Google.CredentialsMode == "oauth2" {
+		oauthServer, err = startOAuthCallbackServer(cfg.Google.CallbackListenAddr, cfg.Google.RedirectURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start OAuth2 callback server, /reauth won't work: %v\n", err)
+		}
+	}
+
+	registerSheetCommands()
+	registerPreviewCommands(cfg)
+	registerReauthCommand()
+
+	return nil
+}
+
+// SetConfigPath records where the global config was loaded from, so /dryrun can persist its
+// change immediately. main() calls this right after loading config, before Init.
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// ProcessQueue drains and applies whatever NameColourUpdates are currently pending. main() calls
+// this on a ticker, passing the same queue channel every subsystem's Init receives for writing.
+func ProcessQueue(queue <-chan config.NameColourUpdate, store config.Store) {
+	processQueue(queue, currentService(), store)
+}
+
+// newSheetsService authenticates with Google using whichever credentialsSource cfg selects, and
+// returns a ready-to-use Sheets API client.
+func newSheetsService(cfg *config.Config) (*sheets.Service, error) {
+	ctx := context.Background()
+
+	source, err := newCredentialsSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := source.Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate with Google: %v", err)
+	}
+
+	srv, err := sheets.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %v", err)
+	}
+
+	return srv, nil
+}
+
+// registerReauthCommand registers /reauth, which DMs a bot owner a fresh Google authorization
+// link when the refresh token has been revoked.
+func registerReauthCommand() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "reauth",
+		Description: "DMs you a fresh Google authorization link, for when the refresh token has been revoked",
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "reauth") {
+			return
+		}
+
+		if !config.IsBotOwner(i.Member.User.ID) {
+			messageContent := "Sorry, only a bot owner can request reauthorization."
+			commands.Respond(session, i.Interaction, &messageContent)
+			return
+		}
+
+		if oauthServer == nil {
+			messageContent := "I'm not set up for OAuth2 reauthorization (check Google.CredentialsMode)."
+			commands.Respond(session, i.Interaction, &messageContent)
+			return
+		}
+
+		messageContent := "I've sent you a DM with a fresh authorization link."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		config.Mutex.RLock()
+		oauthConfig, err := reauthOAuthConfig(config.Current)
+		cfg := config.Current
+		config.Mutex.RUnlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed loading OAuth2 config for reauth: %v\n", err)
+			return
+		}
+
+		if err := oauthServer.RequestReauth(session, i.Member.User.ID, oauthConfig, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed requesting reauthorization: %v\n", err)
+		}
+	})
+}
+
+// registerSheetCommands registers /sheet, /setsheet, /ranges, /addrange, and /setranges.
+func registerSheetCommands() {
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "sheet",
+		Description: "Gets the Google Sheets spreadsheet URL that is used for updates",
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "sheet") {
+			return
+		}
+
+		messageContent := "Getting sheet ID..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't get the sheet ID"
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		sheetID := config.GuildConfigOrDefault(i.GuildID).SheetID
+		if sheetID == "" {
+			messageUpdateContent = "I don't have a sheet ID set, so I won't be able to do any name updates. You can give me one with `/setsheet`."
+		} else {
+			messageUpdateContent = fmt.Sprintf("This is the sheet I'll use when updating name colours: https://docs.google.com/spreadsheets/d/%s/", sheetID)
+		}
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "setsheet",
+		Description: "Sets the Google Sheets spreadsheet ID to update",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "sheet-id",
+				Description: "Google Sheets spreadsheet ID",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "setsheet") {
+			return
+		}
+
+		messageContent := "Setting sheet ID..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't update the sheet ID"
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		sheetId := i.ApplicationCommandData().Options[0].StringValue()
+
+		guildConfig := config.GuildConfigOrDefault(i.GuildID)
+		guildConfig.SheetID = sheetId
+		if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+			messageUpdateContent = "I couldn't save the sheet ID."
+			return
+		}
+		messageUpdateContent = fmt.Sprintf("Set the sheet ID to `%s`", sheetId)
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "ranges",
+		Description: "Shows the current allowed ranges in the spreadsheet",
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "ranges") {
+			return
+		}
+
+		messageContent := "Getting ranges..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't get the sheet ranges."
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		ranges := config.GuildConfigOrDefault(i.GuildID).SheetRanges()
+		if len(ranges) == 0 {
+			messageUpdateContent = "I don't have any ranges to look for matches in. You can add some with `/addrange` or `/setranges`."
+		} else {
+			messageUpdateContent = fmt.Sprintf("Here %s the current %s I'll look for matches in: `%s`", textutil.Pluralise("is", "are", len(ranges)), textutil.Pluralise("range", "ranges", len(ranges)), strings.Join(ranges, "`, `"))
+		}
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "addrange",
+		Description: "Adds an allowed range for the spreadsheet",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "range",
+				Description: "Cell range represented in A1 notation (e.g. `E8`, `C2:D17`, `'My Other Sheet'!AE2:AF357`, etc.)",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "addrange") {
+			return
+		}
+
+		messageContent := "Adding range..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "Done!"
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		options := i.Interaction.ApplicationCommandData().Options
+		var value string
+		guildConfig := config.GuildConfigOrDefault(i.GuildID)
+		if len(options) > 0 {
+			value = options[0].StringValue()
+
+			_, err := sheetutil.ParseA1Notation(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid A1 notation range from addrange: %v", err)
+				messageUpdateContent = fmt.Sprintf("`%s` doesn't look like a valid range in A1 notation. Check these examples from Google to see what I mean: https://developers.google.com/sheets/api/guides/concepts#expandable-1", value)
+				return
+			}
+
+			guildConfig.AddSheetRange(value)
+			if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+				messageUpdateContent = "I couldn't save the range."
+				return
+			}
+		}
+
+		currentRanges := guildConfig.SheetRanges()
+		messageUpdateContent = fmt.Sprintf("Added `%s` to the range list. %s that I'll look for matches in now: `%s`", value, textutil.Pluralise("This is the one", "These are the ones", len(currentRanges)), strings.Join(currentRanges, "`, `"))
+	})
+
+	commands.Register(&discordgo.ApplicationCommand{
+		Name:        "setranges",
+		Description: "Sets the ranges to update in the spreadsheet",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "ranges",
+				Description: "One or more ranges in A1 notation separated by a space (e.g. `E8 F2 G12`, `C2:D7 E2:G9 A1:D1`, etc.)",
+				Required:    true,
+			},
+		},
+	}, func(session *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !commands.Assert(i, "setranges") {
+			return
+		}
+
+		messageContent := "Setting ranges..."
+		commands.Respond(session, i.Interaction, &messageContent)
+
+		messageUpdateContent := "I couldn't set the ranges."
+		defer commands.Update(session, i.Interaction, &messageUpdateContent)
+
+		options := i.Interaction.ApplicationCommandData().Options
+		newRanges := set.NewSet[string]()
+		if len(options) > 0 {
+			matches := a1RangeTokenPattern.FindAllString(options[0].StringValue(), -1)
+			for matchIndex, match := range matches {
+				value := strings.TrimSpace(match)
+
+				_, err := sheetutil.ParseA1Notation(value)
+				if err != nil {
+					messageUpdateContent = fmt.Sprintf("I got %d %s in but `%s` doesn't look like a valid range in A1 notation", matchIndex, textutil.Pluralise("match", "matches", matchIndex+1), match)
+					return
+				}
+
+				newRanges.Add(value)
+			}
+		} else {
+			return
+		}
+
+		newRangesSlice := newRanges.ToSlice()
+		guildConfig := config.GuildConfigOrDefault(i.GuildID)
+		guildConfig.SetSheetRanges(newRangesSlice)
+		if err := config.GuildStore.Put(i.GuildID, guildConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed saving guild config: %v\n", err)
+			messageUpdateContent = "I couldn't save the ranges."
+			return
+		}
+
+		switch len(newRangesSlice) {
+		case 0:
+			messageUpdateContent = fmt.Sprintf("I couldn't find any valid A1 notation ranges in the list you gave me: `%s`", options[0].StringValue())
+			return
+		case 1:
+			messageUpdateContent = fmt.Sprintf("Replaced the range list with `%s`.", newRangesSlice[0])
+		default:
+			messageUpdateContent = fmt.Sprintf("Replaced the range list with the following: `%s`", strings.Join(newRangesSlice, "`, `"))
+		}
+	})
+}
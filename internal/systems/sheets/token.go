@@ -0,0 +1,216 @@
+package sheets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// headlessFlag forces the paste-based auth code flow, for use over SSH where there's no browser
+// to redirect back to a local port.
+var headlessFlag = flag.Bool("headless", false, "Use the paste-based auth code flow instead of the local loopback redirect")
+
+// Retrieves a token, saves it, then returns a client backed by a NotifyingTokenSource so that
+// any refresh performed behind the scenes gets persisted back to tokenPath.
+func getClient(tokenPath string, config *oauth2.Config) *http.Client {
+	tok, err := tokenFromFile(tokenPath)
+	if err != nil {
+		tok = getTokenFromWeb(config)
+		saveToken(tokenPath, tok)
+	}
+
+	source := &NotifyingTokenSource{
+		tokenPath: tokenPath,
+		source:    config.TokenSource(context.Background(), tok),
+		lastToken: tok,
+	}
+	return oauth2.NewClient(context.Background(), source)
+}
+
+// NotifyingTokenSource wraps an oauth2.TokenSource and persists the token to disk via saveToken
+// whenever the underlying source hands back a token that differs from the last one we saw, i.e.
+// whenever it's been silently refreshed.
+type NotifyingTokenSource struct {
+	tokenPath string
+	source    oauth2.TokenSource
+	mutex     sync.Mutex
+	lastToken *oauth2.Token
+}
+
+// Token returns the current token, persisting it to tokenPath first if it has changed since the
+// last call.
+func (s *NotifyingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.lastToken == nil || tok.AccessToken != s.lastToken.AccessToken || !tok.Expiry.Equal(s.lastToken.Expiry) {
+		saveToken(s.tokenPath, tok)
+		s.lastToken = tok
+	}
+
+	return tok, nil
+}
+
+// Requests a token from the web, then returns the retrieved token. Normally this spins up a
+// local loopback server and drives the whole redirect dance; pass --headless to fall back to
+// pasting the auth code back in, for SSH sessions without a browser to redirect to.
+func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+	if *headlessFlag {
+		return getTokenFromWebHeadless(config)
+	}
+	return getTokenFromWebLoopback(config)
+}
+
+// getTokenFromWebLoopback opens a one-shot local HTTP server on an ephemeral loopback port, sets
+// it as the OAuth2 redirect URL, opens the consent screen in the user's browser, and waits for
+// the resulting redirect to hand back an authorization code.
+func getTokenFromWebLoopback(config *oauth2.Config) *oauth2.Token {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Unable to start local loopback listener: %v", err)
+	}
+
+	state, err := randomState()
+	if err != nil {
+		log.Fatalf("Unable to generate OAuth2 state: %v", err)
+	}
+
+	// Point the OAuth2 config's redirect at wherever we just bound to
+	loopbackConfig := *config
+	loopbackConfig.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr().String())
+
+	type result struct {
+		code string
+		err  error
+	}
+	resultCh := make(chan result, 1)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if query.Get("state") != state {
+				http.Error(w, "Invalid state, please try again", http.StatusBadRequest)
+				resultCh <- result{err: errors.New("state mismatch in OAuth2 redirect")}
+				return
+			}
+
+			if errMsg := query.Get("error"); errMsg != "" {
+				http.Error(w, fmt.Sprintf("Authorization failed: %s", errMsg), http.StatusBadRequest)
+				resultCh <- result{err: fmt.Errorf("authorization denied: %s", errMsg)}
+				return
+			}
+
+			fmt.Fprint(w, "Authorization complete, you can close this tab and return to Rolly.")
+			resultCh <- result{code: query.Get("code")}
+		}),
+	}
+	defer server.Close()
+	go server.Serve(listener)
+
+	authURL := loopbackConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	fmt.Printf("Opening the following link in your browser to authorize Rolly:\n%v\n", authURL)
+	if err := openBrowser(authURL); err != nil {
+		fmt.Printf("Couldn't open a browser automatically (%v), open the link above manually.\n", err)
+	}
+
+	res := <-resultCh
+	if res.err != nil {
+		log.Fatalf("Unable to complete OAuth2 redirect: %v", res.err)
+	}
+
+	tok, err := loopbackConfig.Exchange(context.TODO(), res.code)
+	if err != nil {
+		log.Fatalf("Unable to retrieve token from web: %v", err)
+	}
+	return tok
+}
+
+// getTokenFromWebHeadless prints the auth URL and blocks on the user pasting back the resulting
+// authorization code, for use when there's no local browser to redirect to (e.g. over SSH).
+func getTokenFromWebHeadless(config *oauth2.Config) *oauth2.Token {
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	fmt.Printf("Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	var authCode string
+	if _, err := fmt.Scan(&authCode); err != nil {
+		log.Fatalf("Unable to read authorization code: %v", err)
+	}
+
+	tok, err := config.Exchange(context.TODO(), authCode)
+	if err != nil {
+		log.Fatalf("Unable to retrieve token from web: %v", err)
+	}
+	return tok
+}
+
+// randomState generates a cryptographically random state value to guard against CSRF on the
+// OAuth2 redirect.
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// openBrowser opens the given URL in the user's default browser, using whichever opener command
+// is appropriate for the current platform.
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// Retrieves a token from a local file.
+func tokenFromFile(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tok := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+// Saves a token to a file path, truncating any previous contents and fsyncing before returning.
+// The file is rewritten in place rather than replaced atomically.
+func saveToken(path string, token *oauth2.Token) {
+	fmt.Printf("Saving credential file to: %s\n", path)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Fatalf("Unable to cache oauth token: %v", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(token); err != nil {
+		log.Fatalf("Unable to write oauth token: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		log.Fatalf("Unable to sync oauth token file: %v", err)
+	}
+}
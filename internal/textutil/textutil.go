@@ -0,0 +1,10 @@
+// Package textutil holds small string-formatting helpers shared across subsystems.
+package textutil
+
+// Pluralise returns singular if n is 1, otherwise plural.
+func Pluralise(singular string, plural string, n int) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}